@@ -1,10 +1,10 @@
 // Package api available to user functions. User function must be a type
-// that implements either Function or ReturnFunction interface.
+// that implements one of Function, ReturnFunction, ErrorFunction or ReturnErrorFunction interfaces.
 // Function type for structs MUST be implemented on pointer reciever.
 // User's function type MUST be exported using variable named Function (default) or otherwise defined EntryPoint in function.json (according to GoLang export rules, name MUST begin with capital letter). EntryPoint must be a valid GoLang identifier (https://golang.org/ref/spec#Identifiers).
 // Inputs and outputs are read and written in similar fashion to a encoding/json package
 //
-// If a function object is a struct, the field name or a tag named `azfunc` must match trigger type for triggers and binding name for bindings. Field tag takes priority over field name. Field must be exported. If there's no tag, field name is compared using Unicode case-folding.
+// If a function object is a struct, the field name or a tag named `azfunc` must match trigger type or trigger binding name for triggers and binding name for bindings. Field tag takes priority over field name. Field must be exported. If there's no tag, field name is compared using Unicode case-folding.
 // If a function object is a map, keys in map match the type of a trigger for function triggers and a name of a binding for the rest of bindings.
 //
 // It is not an error if binding is missing from Function struct.
@@ -39,6 +39,21 @@
 //  }
 //  var Function *HTTPTrigger
 //
+// Functions can fail invocation by returning an error, using ErrorFunction or ReturnErrorFunction
+//  package main
+//  type HTTPTrigger struct {
+//  	HttpTrigger *api.Request `azfunc:"httpTrigger"`
+//  }
+//  func (f *HTTPTrigger) Run(ctx context.Context, logger api.Logger) (interface{}, error) {
+//  	if f.HttpTrigger.Method != http.MethodGet {
+//  		return nil, errors.New("unsupported method")
+//  	}
+//  	return api.Response{
+//  		Body: "data",
+//  	}, nil
+//  }
+//  var Function *HTTPTrigger
+//
 // Worker also supports simple map type definitions as function objects
 //  package main
 //  type HTTPTrigger map[string]interface{}
@@ -49,6 +64,33 @@
 //  }
 //  var Function HTTPTrigger
 //
+// Resources shared by invocations can be created once by implementing Initializer
+//  package main
+//  type HTTPTrigger struct {
+//  	HttpTrigger *api.Request `azfunc:"httpTrigger"`
+//  	DB          *sql.DB
+//  }
+//  func (f *HTTPTrigger) Init(ctx context.Context, logger api.Logger) (err error) {
+//  	f.DB, err = sql.Open("postgres", os.Getenv("DATABASE_URL"))
+//  	return
+//  }
+//  func (f *HTTPTrigger) Close() error {
+//  	return f.DB.Close()
+//  }
+//
+// Services shared by functions can be injected into fields tagged with `azfunc:"inject"`,
+// see Services
+//  package main
+//  type HTTPTrigger struct {
+//  	HttpTrigger *api.Request `azfunc:"httpTrigger"`
+//  	DB          *sql.DB      `azfunc:"inject"`
+//  }
+//  func RegisterServices(services *api.Services) error {
+//  	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+//  	services.Register(db)
+//  	return err
+//  }
+//
 // If scriptFile in function.json is empty, whole function package is built, similar to `go build .`, otherwise only file indicated by scriptFile is built and other go sources in function directory are ignored.
 package api
 
@@ -91,6 +133,30 @@ type ReturnFunction interface {
 	Run(context.Context, Logger) interface{}
 }
 
+// ErrorFunction interface that must be implemented by user's function object. Function
+// does not return a value, but can fail invocation by returning an error.
+type ErrorFunction interface {
+	Run(context.Context, Logger) error
+}
+
+// ReturnErrorFunction interface that must be implemented by user's function object. Function
+// returns a value or fails invocation by returning an error.
+type ReturnErrorFunction interface {
+	Run(context.Context, Logger) (interface{}, error)
+}
+
+// Initializer can be implemented by user's function object to initialize resources shared
+// by invocations, like database connection pools or http clients. Init is called once when
+// function is loaded and objects of all invocations are copies of the initialized object,
+// for map function objects entries of map are copied. Function fails to load if Init
+// returns an error.
+//
+// Function object can also implement io.Closer to release resources. Close is called on the
+// initialized object when worker terminates or when function is reloaded.
+type Initializer interface {
+	Init(context.Context, Logger) error
+}
+
 // Request represents httpTrigger in function definition.
 type Request struct {
 	Method  string
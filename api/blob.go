@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// BlobProperties are system properties of blob
+type BlobProperties struct {
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	ContentMD5         string
+	ContentType        string
+	ETag               string
+	Length             int64
+	Created            time.Time
+	LastModified       time.Time
+}
+
+// blobPropertiesJSON is BlobProperties sent by host with times that may be missing timezone
+type blobPropertiesJSON struct {
+	BlobProperties
+	Created      hostTime
+	LastModified hostTime
+}
+
+// Blob represents blobTrigger and blob bindings in function definition.
+//
+// Path, URI, Properties and Metadata are only set for blobTrigger. Besides Blob, output blob
+// binding can be a field of type []byte, string, io.Reader or a type implementing converters.Marshaler.
+type Blob struct {
+	// Path of blob that triggered function, in a form of container/name
+	Path string
+	// URI of blob
+	URI string
+	// Properties of blob
+	Properties BlobProperties
+	// Metadata of blob
+	Metadata map[string]string
+	// DataType of content sent by host, one of DataTypeString, DataTypeBinary or DataTypeStream.
+	// It is the data type of binding, if defined in function.json.
+	DataType string
+	// Content of blob
+	Content []byte
+}
+
+// Reader returns reader of blob content
+func (b *Blob) Reader() io.Reader {
+	return bytes.NewReader(b.Content)
+}
+
+// String returns blob content as string
+func (b *Blob) String() string {
+	return string(b.Content)
+}
+
+// Unmarshal implements unmarshaler for api.Blob
+func (b *Blob) Unmarshal(data *rpc.TypedData) error {
+	return b.UnmarshalTrigger(data, nil)
+}
+
+// UnmarshalTrigger implements trigger unmarshaler for api.Blob
+func (b *Blob) UnmarshalTrigger(data *rpc.TypedData, metadata map[string]*rpc.TypedData) error {
+	blob := Blob{
+		Path: metadataString(metadata, "BlobTrigger"),
+		URI:  metadataString(metadata, "Uri"),
+	}
+	switch td := data.Data.(type) {
+	case *rpc.TypedData_String_:
+		blob.DataType, blob.Content = DataTypeString, []byte(td.String_)
+	case *rpc.TypedData_Bytes:
+		blob.DataType, blob.Content = DataTypeBinary, td.Bytes
+	case *rpc.TypedData_Stream:
+		blob.DataType, blob.Content = DataTypeStream, td.Stream
+	case *rpc.TypedData_Json:
+		blob.DataType, blob.Content = DataTypeString, []byte(td.Json)
+	default:
+		return errors.Errorf("not a blob")
+	}
+	if s := metadataString(metadata, "Properties"); s != "" {
+		var properties blobPropertiesJSON
+		if err := json.Unmarshal([]byte(s), &properties); err != nil {
+			return errors.Wrap(err, "invalid Properties")
+		}
+		blob.Properties = properties.BlobProperties
+		blob.Properties.Created = time.Time(properties.Created)
+		blob.Properties.LastModified = time.Time(properties.LastModified)
+	}
+	if s := metadataString(metadata, "Metadata"); s != "" {
+		if err := json.Unmarshal([]byte(s), &blob.Metadata); err != nil {
+			return errors.Wrap(err, "invalid Metadata")
+		}
+	}
+	*b = blob
+	return nil
+}
+
+// Marshal implements marshaler for api.Blob. Content is sent as string if data type
+// of blob is DataTypeString, otherwise it is sent as binary data.
+func (b Blob) Marshal() (*rpc.TypedData, error) {
+	if b.DataType == DataTypeString {
+		return &rpc.TypedData{
+			Data: &rpc.TypedData_String_{
+				String_: string(b.Content),
+			},
+		}, nil
+	}
+	return &rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{
+			Bytes: b.Content,
+		},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobUnmarshalTrigger(t *testing.T) {
+	var blob api.Blob
+	assert.NoError(t, blob.UnmarshalTrigger(&rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{Bytes: []byte("content")},
+	}, map[string]*rpc.TypedData{
+		"BlobTrigger": &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "samples/blob.txt"}},
+		"Uri":         &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `"https://mock.blob.core.windows.net/samples/blob.txt"`}},
+		"Properties": &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `{
+			"CacheControl": null,
+			"ContentType": "text/plain",
+			"ETag": "\"0x8D7C0\"",
+			"Length": 7,
+			"LastModified": "2020-03-04T10:15:00+00:00",
+			"BlobType": 2
+		}`}},
+		"Metadata": &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `{"author":"mock"}`}},
+	}))
+	assert.Equal(t, "samples/blob.txt", blob.Path)
+	assert.Equal(t, "https://mock.blob.core.windows.net/samples/blob.txt", blob.URI)
+	assert.Equal(t, "text/plain", blob.Properties.ContentType)
+	assert.Equal(t, `"0x8D7C0"`, blob.Properties.ETag)
+	assert.Equal(t, int64(7), blob.Properties.Length)
+	assert.True(t, blob.Properties.LastModified.Equal(time.Date(2020, 3, 4, 10, 15, 0, 0, time.UTC)))
+	assert.True(t, blob.Properties.Created.IsZero())
+	assert.Equal(t, map[string]string{"author": "mock"}, blob.Metadata)
+	assert.Equal(t, api.DataTypeBinary, blob.DataType)
+	assert.Equal(t, "content", blob.String())
+	b, err := ioutil.ReadAll(blob.Reader())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("content"), b)
+	assert.EqualError(t, blob.UnmarshalTrigger(&rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{Bytes: []byte("content")},
+	}, map[string]*rpc.TypedData{
+		"Metadata": &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `[]`}},
+	}), "invalid Metadata: json: cannot unmarshal array into Go value of type map[string]string")
+}
+
+func TestBlobUnmarshal(t *testing.T) {
+	var blob api.Blob
+	assert.NoError(t, blob.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_String_{String_: "content"},
+	}))
+	assert.Equal(t, api.Blob{
+		DataType: api.DataTypeString,
+		Content:  []byte("content"),
+	}, blob)
+	assert.NoError(t, blob.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Stream{Stream: []byte("stream")},
+	}))
+	assert.Equal(t, api.DataTypeStream, blob.DataType)
+	assert.EqualError(t, blob.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Int{Int: 1},
+	}), "not a blob")
+}
+
+func TestBlobMarshal(t *testing.T) {
+	td, err := api.Blob{Content: []byte("content")}.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: []byte("content")}}, td)
+	td, err = api.Blob{DataType: api.DataTypeString, Content: []byte("content")}.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "content"}}, td)
+}
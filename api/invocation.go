@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// Binding directions as defined in function.json
+const (
+	DirectionIn    = "in"
+	DirectionOut   = "out"
+	DirectionInOut = "inout"
+)
+
+// Binding data types as defined in function.json, empty data type means data type was not defined
+const (
+	DataTypeString = "string"
+	DataTypeBinary = "binary"
+	DataTypeStream = "stream"
+)
+
+// BindingInfo describes a binding defined in function.json
+type BindingInfo struct {
+	// Type of binding (e.g. httpTrigger)
+	Type string
+	// Direction of binding, one of DirectionIn, DirectionOut or DirectionInOut
+	Direction string
+	// DataType of binding, one of DataTypeString, DataTypeBinary, DataTypeStream or empty
+	DataType string
+}
+
+// Bindings by binding name
+type Bindings map[string]BindingInfo
+
+// FunctionInfo describes invoked function
+type FunctionInfo struct {
+	// ID of function assigned by host
+	ID string
+	// Name of function
+	Name string
+	// Directory of function
+	Directory string
+	// ScriptFile from function.json
+	ScriptFile string
+	// EntryPoint of function
+	EntryPoint string
+	// TriggerBindingName is a name of trigger binding
+	TriggerBindingName string
+	// Trigger binding of function
+	Trigger BindingInfo
+	// InputBindings of function, without trigger
+	InputBindings Bindings
+	// OutputBindings of function, including $return if defined
+	OutputBindings Bindings
+}
+
+// InvocationInfo describes a single invocation of function
+type InvocationInfo struct {
+	// InvocationID assigned by host
+	InvocationID string
+	// Function that is invoked
+	Function FunctionInfo
+	// TraceContext of invocation, it is not valid if host did not send a valid trace context
+	TraceContext TraceContext
+	// Deadline of invocation, zero if invocation has no timeout
+	Deadline time.Time
+}
+
+type invocationInfoKey struct{}
+
+// ContextWithInvocationInfo returns context with invocation info
+func ContextWithInvocationInfo(ctx context.Context, info InvocationInfo) context.Context {
+	return context.WithValue(ctx, invocationInfoKey{}, info)
+}
+
+// GetInvocationInfo returns info of invocation. Returns false if context is not
+// a context of invocation.
+func GetInvocationInfo(ctx context.Context) (InvocationInfo, bool) {
+	info, ok := ctx.Value(invocationInfoKey{}).(InvocationInfo)
+	return info, ok
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level of log message
+type Level int
+
+// Log levels, ordered from the least to the most severe
+const (
+	TraceLevel Level = iota
+	DebugLevel
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+// StructuredLogger is a Logger that attaches key value pairs to messages. Logger passed to
+// function by worker implements StructuredLogger, use Structured to access it.
+//
+// Key value pairs are passed as alternating keys and values, for example
+//  logger.With("user", user.ID).Infow("order created", "order", order.ID, "total", order.Total)
+type StructuredLogger interface {
+	Logger
+	// With returns logger that attaches key value pair to all messages
+	With(key string, value interface{}) StructuredLogger
+	// Enabled returns false if messages with level are discarded by logger
+	Enabled(level Level) bool
+	// Log message with level and key value pairs
+	Log(level Level, msg string, keysAndValues ...interface{})
+	Tracew(msg string, keysAndValues ...interface{})
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	Fatalw(msg string, keysAndValues ...interface{})
+}
+
+// Attr is a key value pair attached to log message
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// Attrs converts alternating keys and values to a list of key value pairs. Keys that are
+// not strings are formatted with fmt.Sprint and a key without value has nil value.
+func Attrs(keysAndValues ...interface{}) []Attr {
+	attrs := make([]Attr, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value interface{}
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		attrs = append(attrs, Attr{Key: key, Value: value})
+	}
+	return attrs
+}
+
+// Structured returns logger as StructuredLogger. If logger does not implement
+// StructuredLogger, key value pairs are appended to message as key=value.
+func Structured(logger Logger) StructuredLogger {
+	if sl, ok := logger.(StructuredLogger); ok {
+		return sl
+	}
+	return textLogger{Logger: logger}
+}
+
+type textLogger struct {
+	Logger
+	attrs []Attr
+}
+
+func (t textLogger) With(key string, value interface{}) StructuredLogger {
+	t.attrs = append(t.attrs[:len(t.attrs):len(t.attrs)], Attr{Key: key, Value: value})
+	return t
+}
+
+func (t textLogger) Enabled(level Level) bool {
+	return true
+}
+
+func (t textLogger) Log(level Level, msg string, keysAndValues ...interface{}) {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, attr := range append(t.attrs[:len(t.attrs):len(t.attrs)], Attrs(keysAndValues...)...) {
+		fmt.Fprintf(&sb, " %s=%v", attr.Key, attr.Value)
+	}
+	switch level {
+	case TraceLevel:
+		t.Trace(sb.String())
+	case DebugLevel:
+		t.Debug(sb.String())
+	case InfoLevel:
+		t.Info(sb.String())
+	case WarnLevel:
+		t.Warn(sb.String())
+	case ErrorLevel:
+		t.Error(sb.String())
+	default:
+		t.Fatal(sb.String())
+	}
+}
+
+func (t textLogger) Tracew(msg string, keysAndValues ...interface{}) {
+	t.Log(TraceLevel, msg, keysAndValues...)
+}
+
+func (t textLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	t.Log(DebugLevel, msg, keysAndValues...)
+}
+
+func (t textLogger) Infow(msg string, keysAndValues ...interface{}) {
+	t.Log(InfoLevel, msg, keysAndValues...)
+}
+
+func (t textLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	t.Log(WarnLevel, msg, keysAndValues...)
+}
+
+func (t textLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	t.Log(ErrorLevel, msg, keysAndValues...)
+}
+
+func (t textLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	t.Log(FatalLevel, msg, keysAndValues...)
+}
@@ -0,0 +1,29 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttrs(t *testing.T) {
+	assert.Equal(t, []api.Attr{
+		{Key: "a", Value: 1},
+		{Key: "2", Value: "b"},
+		{Key: "c", Value: nil},
+	}, api.Attrs("a", 1, 2, "b", "c"))
+}
+
+func TestStructuredFallback(t *testing.T) {
+	var logger mocks.Logger
+	logger.On("Warn", "msg user=1 order=2")
+	api.Structured(&logger).With("user", 1).Warnw("msg", "order", 2)
+	logger.AssertExpectations(t)
+}
+
+func TestStructuredReturnsStructuredLogger(t *testing.T) {
+	var logger mocks.StructuredLogger
+	assert.Equal(t, &logger, api.Structured(&logger))
+}
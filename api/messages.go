@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/converters"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// marshalMessages marshals messages of output binding, single message is sent as is
+// and multiple messages are sent as JSON array
+func marshalMessages(messages []interface{}) (*rpc.TypedData, error) {
+	switch len(messages) {
+	case 0:
+		return nil, nil
+	case 1:
+		return marshalMessage(messages[0])
+	}
+	raws := make([]json.RawMessage, 0, len(messages))
+	for _, message := range messages {
+		td, err := marshalMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		var raw []byte
+		switch d := td.GetData().(type) {
+		case *rpc.TypedData_Json:
+			raw = []byte(d.Json)
+		case *rpc.TypedData_String_:
+			raw, err = json.Marshal(d.String_)
+		case *rpc.TypedData_Bytes:
+			raw, err = json.Marshal(string(d.Bytes))
+		default:
+			var v interface{}
+			if v, err = converters.Unmarshal(td); err == nil {
+				raw, err = json.Marshal(v)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid message")
+		}
+		raws = append(raws, raw)
+	}
+	b, err := json.Marshal(raws)
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: string(b),
+		},
+	}, nil
+}
+
+func marshalMessage(message interface{}) (*rpc.TypedData, error) {
+	switch m := message.(type) {
+	case string:
+		return &rpc.TypedData{
+			Data: &rpc.TypedData_String_{
+				String_: m,
+			},
+		}, nil
+	case []byte:
+		return &rpc.TypedData{
+			Data: &rpc.TypedData_Bytes{
+				Bytes: m,
+			},
+		}, nil
+	case converters.Marshaler:
+		return m.Marshal()
+	}
+	b, err := json.Marshal(message)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid message")
+	}
+	return &rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: string(b),
+		},
+	}, nil
+}
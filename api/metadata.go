@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// hostTime is a time sent by host, which can be missing timezone, in which case it is in UTC
+type hostTime time.Time
+
+var hostTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+}
+
+func (h *hostTime) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	return h.parse(strings.Trim(s, `"`))
+}
+
+func (h *hostTime) parse(s string) error {
+	var err error
+	for _, layout := range hostTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			*h = hostTime(t)
+			return nil
+		}
+	}
+	return errors.Wrapf(err, "invalid time %s", s)
+}
+
+// metadataString returns trigger metadata value as string, JSON strings are unquoted
+func metadataString(metadata map[string]*rpc.TypedData, key string) string {
+	switch td := metadata[key].GetData().(type) {
+	case *rpc.TypedData_String_:
+		return td.String_
+	case *rpc.TypedData_Json:
+		var s string
+		if err := json.Unmarshal([]byte(td.Json), &s); err == nil {
+			return s
+		}
+		return td.Json
+	case *rpc.TypedData_Bytes:
+		return string(td.Bytes)
+	case *rpc.TypedData_Int:
+		return strconv.FormatInt(td.Int, 10)
+	case *rpc.TypedData_Double:
+		return strconv.FormatFloat(td.Double, 'f', -1, 64)
+	}
+	return ""
+}
+
+// metadataInt returns trigger metadata value as int64, missing value is 0
+func metadataInt(metadata map[string]*rpc.TypedData, key string) (int64, error) {
+	switch td := metadata[key].GetData().(type) {
+	case nil:
+		return 0, nil
+	case *rpc.TypedData_Int:
+		return td.Int, nil
+	case *rpc.TypedData_Double:
+		return int64(td.Double), nil
+	}
+	i, err := strconv.ParseInt(metadataString(metadata, key), 10, 64)
+	return i, errors.Wrapf(err, "invalid %s", key)
+}
+
+// metadataTime returns trigger metadata value as time, missing value is zero time
+func metadataTime(metadata map[string]*rpc.TypedData, key string) (time.Time, error) {
+	s := metadataString(metadata, key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	var t hostTime
+	err := t.parse(s)
+	return time.Time(t), errors.Wrapf(err, "invalid %s", key)
+}
+
+// metadataJSON unmarshals JSON trigger metadata value into v, missing value is ignored
+func metadataJSON(metadata map[string]*rpc.TypedData, key string, v interface{}) error {
+	s := metadataString(metadata, key)
+	if s == "" {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal([]byte(s), v), "invalid %s", key)
+}
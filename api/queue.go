@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// QueueMessage represents queueTrigger in function definition.
+type QueueMessage struct {
+	// ID of message
+	ID string
+	// Body of message, a string, a []byte or, if message is JSON, a decoded JSON value
+	Body interface{}
+	// RawBody of message
+	RawBody []byte
+	// DequeueCount is a number of times message was dequeued
+	DequeueCount int64
+	// InsertionTime of message
+	InsertionTime time.Time
+	// ExpirationTime of message
+	ExpirationTime time.Time
+	// NextVisibleTime of message
+	NextVisibleTime time.Time
+	// PopReceipt of message
+	PopReceipt string
+}
+
+// DecodeBody unmarshals JSON body of message into v
+func (m *QueueMessage) DecodeBody(v interface{}) error {
+	return json.Unmarshal(m.RawBody, v)
+}
+
+// Unmarshal implements unmarshaler for api.QueueMessage, message has no metadata
+func (m *QueueMessage) Unmarshal(data *rpc.TypedData) error {
+	return m.UnmarshalTrigger(data, nil)
+}
+
+// UnmarshalTrigger implements trigger unmarshaler for api.QueueMessage
+func (m *QueueMessage) UnmarshalTrigger(data *rpc.TypedData, metadata map[string]*rpc.TypedData) error {
+	msg := QueueMessage{
+		ID:         metadataString(metadata, "Id"),
+		PopReceipt: metadataString(metadata, "PopReceipt"),
+	}
+	switch td := data.Data.(type) {
+	case *rpc.TypedData_String_:
+		msg.Body, msg.RawBody = td.String_, []byte(td.String_)
+	case *rpc.TypedData_Bytes:
+		msg.Body, msg.RawBody = td.Bytes, td.Bytes
+	case *rpc.TypedData_Json:
+		msg.RawBody = []byte(td.Json)
+		if err := json.Unmarshal(msg.RawBody, &msg.Body); err != nil {
+			return errors.Wrap(err, "invalid queue message")
+		}
+	default:
+		return errors.Errorf("not a queue message")
+	}
+	var err error
+	msg.DequeueCount, err = metadataInt(metadata, "DequeueCount")
+	if err == nil {
+		msg.InsertionTime, err = metadataTime(metadata, "InsertionTime")
+	}
+	if err == nil {
+		msg.ExpirationTime, err = metadataTime(metadata, "ExpirationTime")
+	}
+	if err == nil {
+		msg.NextVisibleTime, err = metadataTime(metadata, "NextVisibleTime")
+	}
+	if err == nil {
+		*m = msg
+	}
+	return err
+}
+
+// QueueOutput represents queue output binding. Each message is enqueued as a separate queue message.
+// Strings and byte slices are enqueued as is, messages implementing converters.Marshaler
+// are marshaled with Marshal and other values are encoded as JSON.
+type QueueOutput []interface{}
+
+// Add messages to output
+func (q *QueueOutput) Add(messages ...interface{}) {
+	*q = append(*q, messages...)
+}
+
+// Marshal implements marshaler for api.QueueOutput
+func (q QueueOutput) Marshal() (*rpc.TypedData, error) {
+	return marshalMessages(q)
+}
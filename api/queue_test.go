@@ -0,0 +1,79 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+var queueTriggerMetadata = map[string]*rpc.TypedData{
+	"Id":              &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "mockID"}},
+	"DequeueCount":    &rpc.TypedData{Data: &rpc.TypedData_Json{Json: "2"}},
+	"InsertionTime":   &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `"2020-03-04T10:15:00+00:00"`}},
+	"ExpirationTime":  &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `"2020-03-11T10:15:00+00:00"`}},
+	"NextVisibleTime": &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "2020-03-04T10:25:00Z"}},
+	"PopReceipt":      &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "mockReceipt"}},
+}
+
+func TestQueueMessageUnmarshalTrigger(t *testing.T) {
+	var msg api.QueueMessage
+	assert.NoError(t, msg.UnmarshalTrigger(&rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: `{"name":"value"}`,
+		},
+	}, queueTriggerMetadata))
+	assert.Equal(t, "mockID", msg.ID)
+	assert.Equal(t, map[string]interface{}{"name": "value"}, msg.Body)
+	assert.Equal(t, int64(2), msg.DequeueCount)
+	assert.True(t, msg.InsertionTime.Equal(time.Date(2020, 3, 4, 10, 15, 0, 0, time.UTC)))
+	assert.True(t, msg.ExpirationTime.Equal(time.Date(2020, 3, 11, 10, 15, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2020, 3, 4, 10, 25, 0, 0, time.UTC), msg.NextVisibleTime)
+	assert.Equal(t, "mockReceipt", msg.PopReceipt)
+	var body struct{ Name string }
+	assert.NoError(t, msg.DecodeBody(&body))
+	assert.Equal(t, "value", body.Name)
+	assert.EqualError(t, msg.UnmarshalTrigger(&rpc.TypedData{
+		Data: &rpc.TypedData_String_{String_: "message"},
+	}, map[string]*rpc.TypedData{
+		"DequeueCount": &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "many"}},
+	}), `invalid DequeueCount: strconv.ParseInt: parsing "many": invalid syntax`)
+	assert.Equal(t, "mockID", msg.ID, "message is not modified on error")
+}
+
+func TestQueueMessageUnmarshal(t *testing.T) {
+	var msg api.QueueMessage
+	assert.NoError(t, msg.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{Bytes: []byte("message")},
+	}))
+	assert.Equal(t, api.QueueMessage{
+		Body:    []byte("message"),
+		RawBody: []byte("message"),
+	}, msg)
+	assert.EqualError(t, msg.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Int{Int: 1},
+	}), "not a queue message")
+}
+
+type intMessage int64
+
+func (i intMessage) Marshal() (*rpc.TypedData, error) {
+	return &rpc.TypedData{Data: &rpc.TypedData_Int{Int: int64(i)}}, nil
+}
+
+func TestQueueOutputMarshal(t *testing.T) {
+	var out api.QueueOutput
+	td, err := out.Marshal()
+	assert.NoError(t, err)
+	assert.Nil(t, td)
+	out.Add("message")
+	td, err = out.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "message"}}, td)
+	out.Add([]byte("bytes"), map[string]int{"n": 1}, intMessage(2))
+	td, err = out.Marshal()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["message", "bytes", {"n": 1}, 2]`, td.GetJson())
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// ServiceBusMessage represents serviceBusTrigger in function definition. Trigger with
+// cardinality many can be a slice of messages, []ServiceBusMessage.
+type ServiceBusMessage struct {
+	// MessageID of message
+	MessageID string
+	// CorrelationID of message
+	CorrelationID string
+	// ContentType of message body
+	ContentType string
+	// DeliveryCount is a number of deliveries of message
+	DeliveryCount int64
+	// EnqueuedTimeUTC is a time at which message was enqueued
+	EnqueuedTimeUTC time.Time
+	// SessionID of message, empty if entity is not session aware
+	SessionID string
+	// UserProperties of message
+	UserProperties map[string]interface{}
+	// Body of message decoded by content type. It is a decoded JSON value for
+	// JSON content, a string for text content and a []byte otherwise.
+	Body interface{}
+	// RawBody of message
+	RawBody []byte
+}
+
+// DecodeBody unmarshals JSON body of message into v
+func (m *ServiceBusMessage) DecodeBody(v interface{}) error {
+	return json.Unmarshal(m.RawBody, v)
+}
+
+// Unmarshal implements unmarshaler for api.ServiceBusMessage, message has no metadata
+func (m *ServiceBusMessage) Unmarshal(data *rpc.TypedData) error {
+	return m.UnmarshalTrigger(data, nil)
+}
+
+func isJSONContentType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// UnmarshalTrigger implements trigger unmarshaler for api.ServiceBusMessage
+func (m *ServiceBusMessage) UnmarshalTrigger(data *rpc.TypedData, metadata map[string]*rpc.TypedData) error {
+	msg := ServiceBusMessage{
+		MessageID:     metadataString(metadata, "MessageId"),
+		CorrelationID: metadataString(metadata, "CorrelationId"),
+		ContentType:   metadataString(metadata, "ContentType"),
+		SessionID:     metadataString(metadata, "SessionId"),
+	}
+	mediaType, _, _ := mime.ParseMediaType(msg.ContentType)
+	isJSON := isJSONContentType(mediaType)
+	switch td := data.Data.(type) {
+	case *rpc.TypedData_Json:
+		msg.RawBody = []byte(td.Json)
+		isJSON = true
+	case *rpc.TypedData_String_:
+		msg.RawBody, msg.Body = []byte(td.String_), td.String_
+	case *rpc.TypedData_Bytes:
+		msg.RawBody, msg.Body = td.Bytes, td.Bytes
+		if strings.HasPrefix(mediaType, "text/") {
+			msg.Body = string(td.Bytes)
+		}
+	default:
+		return errors.Errorf("not a service bus message")
+	}
+	if isJSON {
+		if err := json.Unmarshal(msg.RawBody, &msg.Body); err != nil {
+			return errors.Wrap(err, "invalid service bus message")
+		}
+	}
+	var err error
+	msg.DeliveryCount, err = metadataInt(metadata, "DeliveryCount")
+	if err == nil {
+		msg.EnqueuedTimeUTC, err = metadataTime(metadata, "EnqueuedTimeUtc")
+	}
+	if err == nil {
+		err = metadataJSON(metadata, "UserProperties", &msg.UserProperties)
+	}
+	if err == nil {
+		*m = msg
+	}
+	return err
+}
+
+// ServiceBusOutput represents service bus output binding. Each message is sent as a separate
+// service bus message. Strings and byte slices are sent as is, messages implementing
+// converters.Marshaler are marshaled with Marshal and other values are encoded as JSON.
+type ServiceBusOutput []interface{}
+
+// Add messages to output
+func (s *ServiceBusOutput) Add(messages ...interface{}) {
+	*s = append(*s, messages...)
+}
+
+// Marshal implements marshaler for api.ServiceBusOutput
+func (s ServiceBusOutput) Marshal() (*rpc.TypedData, error) {
+	return marshalMessages(s)
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceBusMessageUnmarshalTrigger(t *testing.T) {
+	var msg api.ServiceBusMessage
+	assert.NoError(t, msg.UnmarshalTrigger(&rpc.TypedData{
+		Data: &rpc.TypedData_String_{String_: `{"name":"value"}`},
+	}, map[string]*rpc.TypedData{
+		"MessageId":       &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "mockMessageID"}},
+		"CorrelationId":   &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "mockCorrelationID"}},
+		"ContentType":     &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "application/json; charset=utf-8"}},
+		"SessionId":       &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "mockSessionID"}},
+		"DeliveryCount":   &rpc.TypedData{Data: &rpc.TypedData_Int{Int: 1}},
+		"EnqueuedTimeUtc": &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `"2020-03-04T10:15:00"`}},
+		"UserProperties":  &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `{"priority":1}`}},
+	}))
+	assert.Equal(t, api.ServiceBusMessage{
+		MessageID:       "mockMessageID",
+		CorrelationID:   "mockCorrelationID",
+		ContentType:     "application/json; charset=utf-8",
+		SessionID:       "mockSessionID",
+		DeliveryCount:   1,
+		EnqueuedTimeUTC: time.Date(2020, 3, 4, 10, 15, 0, 0, time.UTC),
+		UserProperties:  map[string]interface{}{"priority": float64(1)},
+		Body:            map[string]interface{}{"name": "value"},
+		RawBody:         []byte(`{"name":"value"}`),
+	}, msg)
+	var body struct{ Name string }
+	assert.NoError(t, msg.DecodeBody(&body))
+	assert.Equal(t, "value", body.Name)
+}
+
+func TestServiceBusMessageBodyByContentType(t *testing.T) {
+	for _, tt := range []struct {
+		contentType string
+		data        *rpc.TypedData
+		body        interface{}
+	}{
+		{"text/plain", &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: []byte("text")}}, "text"},
+		{"application/octet-stream", &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: []byte("bytes")}}, []byte("bytes")},
+		{"application/vnd.mock+json", &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: []byte("[1]")}}, []interface{}{float64(1)}},
+		{"", &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `"json"`}}, "json"},
+		{"", &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "string"}}, "string"},
+	} {
+		var msg api.ServiceBusMessage
+		assert.NoError(t, msg.UnmarshalTrigger(tt.data, map[string]*rpc.TypedData{
+			"ContentType": &rpc.TypedData{Data: &rpc.TypedData_String_{String_: tt.contentType}},
+		}))
+		assert.Equal(t, tt.body, msg.Body, tt.contentType)
+	}
+	var msg api.ServiceBusMessage
+	assert.EqualError(t, msg.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Int{Int: 1},
+	}), "not a service bus message")
+}
+
+func TestServiceBusOutputMarshal(t *testing.T) {
+	var out api.ServiceBusOutput
+	out.Add(map[string]string{"id": "1"}, "second")
+	td, err := out.Marshal()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id": "1"}, "second"]`, td.GetJson())
+}
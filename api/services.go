@@ -0,0 +1,115 @@
+package api
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// InjectTag is a value of azfunc tag marking fields of function struct that are set
+// from registered services
+const InjectTag = "inject"
+
+// Services is a registry of services shared by functions, like database connection pools,
+// configuration or clients of other services. Services are injected into fields of function struct
+// tagged with `azfunc:"inject"` before function is initialized, so they can be used by Init.
+//
+// Field is set to a service registered with the same type as the type of a field. If
+// field is an interface and no service was registered with that type, field is set to the only
+// registered service that implements it. Function fails to load if service for field cannot be found.
+//
+// Nil Services is an empty registry.
+type Services struct {
+	lock     sync.RWMutex
+	types    []reflect.Type
+	services map[reflect.Type]reflect.Value
+}
+
+// NewServices creates an empty registry
+func NewServices() *Services {
+	return &Services{}
+}
+
+func (s *Services) register(t reflect.Type, v reflect.Value) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.services == nil {
+		s.services = make(map[reflect.Type]reflect.Value)
+	}
+	if _, ok := s.services[t]; !ok {
+		s.types = append(s.types, t)
+	}
+	s.services[t] = v
+}
+
+// Register adds service to registry using it's dynamic type. Service registered
+// earlier with the same type is replaced.
+func (s *Services) Register(service interface{}) {
+	if service == nil {
+		panic("api: Register of nil service")
+	}
+	v := reflect.ValueOf(service)
+	s.register(v.Type(), v)
+}
+
+// RegisterAs adds service to registry using interface type pointed to by iface. It
+// allows choosing one of many services implementing the same interface, for example
+//  services.RegisterAs((*Store)(nil), store)
+func (s *Services) RegisterAs(iface interface{}, service interface{}) {
+	t := reflect.TypeOf(iface)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic("api: RegisterAs requires a pointer to interface")
+	}
+	t = t.Elem()
+	v := reflect.Zero(t)
+	if service != nil {
+		v = reflect.ValueOf(service)
+		if !v.Type().Implements(t) {
+			panic("api: service of type " + v.Type().String() + " does not implement " + t.String())
+		}
+		v = v.Convert(t)
+	}
+	s.register(t, v)
+}
+
+// Resolve returns service that can be assigned to a value of type t
+func (s *Services) Resolve(t reflect.Type) (reflect.Value, error) {
+	if s == nil {
+		return reflect.Value{}, errors.Errorf("service %s is not registered", t.String())
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if v, ok := s.services[t]; ok {
+		return v, nil
+	}
+	var found []reflect.Type
+	if t.Kind() == reflect.Interface {
+		for _, st := range s.types {
+			if st.Implements(t) {
+				found = append(found, st)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return reflect.Value{}, errors.Errorf("service %s is not registered", t.String())
+	case 1:
+		return s.services[found[0]], nil
+	}
+	return reflect.Value{}, errors.Errorf("ambiguous service %s, implemented by %s and %s", t.String(), found[0].String(), found[1].String())
+}
+
+// Clone returns a copy of registry. Services registered in copy do not affect original registry.
+func (s *Services) Clone() *Services {
+	clone := NewServices()
+	if s == nil {
+		return clone
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, t := range s.types {
+		clone.register(t, s.services[t])
+	}
+	return clone
+}
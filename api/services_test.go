@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStore struct {
+	name string
+}
+
+func (m *mockStore) String() string {
+	return m.name
+}
+
+func TestServicesResolve(t *testing.T) {
+	store := &mockStore{name: "store"}
+	services := api.NewServices()
+	services.Register(store)
+	services.Register(strings.NewReader("config"))
+	v, err := services.Resolve(reflect.TypeOf(store))
+	assert.NoError(t, err)
+	assert.Same(t, store, v.Interface())
+	v, err = services.Resolve(reflect.TypeOf((*fmt.Stringer)(nil)).Elem())
+	assert.NoError(t, err)
+	assert.Same(t, store, v.Interface())
+	_, err = services.Resolve(reflect.TypeOf((*io.Writer)(nil)).Elem())
+	assert.EqualError(t, err, "service io.Writer is not registered")
+	_, err = (*api.Services)(nil).Resolve(reflect.TypeOf(store))
+	assert.EqualError(t, err, "service *api_test.mockStore is not registered")
+}
+
+func TestServicesAmbiguousInterface(t *testing.T) {
+	services := api.NewServices()
+	services.Register(&mockStore{name: "first"})
+	services.Register(&strings.Builder{})
+	stringer := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	_, err := services.Resolve(stringer)
+	assert.EqualError(t, err, "ambiguous service fmt.Stringer, implemented by *api_test.mockStore and *strings.Builder")
+	second := &mockStore{name: "second"}
+	services.RegisterAs((*fmt.Stringer)(nil), second)
+	v, err := services.Resolve(stringer)
+	assert.NoError(t, err)
+	assert.Same(t, second, v.Interface())
+	assert.Panics(t, func() { services.RegisterAs(second, second) })
+	assert.Panics(t, func() { services.RegisterAs((*io.Reader)(nil), second) })
+}
+
+func TestServicesClone(t *testing.T) {
+	original := &mockStore{name: "original"}
+	services := api.NewServices()
+	services.Register(original)
+	clone := services.Clone()
+	replaced := &mockStore{name: "replaced"}
+	clone.Register(replaced)
+	v, err := services.Resolve(reflect.TypeOf(original))
+	assert.NoError(t, err)
+	assert.Same(t, original, v.Interface())
+	v, err = clone.Resolve(reflect.TypeOf(original))
+	assert.NoError(t, err)
+	assert.Same(t, replaced, v.Interface())
+}
@@ -0,0 +1,105 @@
+// +build go1.21
+
+package api
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a log/slog Handler that sends records through function logger. Record
+// attributes and groups are sent as key value pairs, with keys of grouped attributes
+// prefixed by group name, for example group.key.
+//
+//  func (f *HTTPTrigger) Run(ctx context.Context, logger api.Logger) {
+//  	log := slog.New(api.NewSlogHandler(logger))
+//  	log.InfoContext(ctx, "request", "method", f.HttpTrigger.Method)
+//  }
+type SlogHandler struct {
+	logger StructuredLogger
+	prefix string
+}
+
+// NewSlogHandler returns log/slog Handler backed by logger
+func NewSlogHandler(logger Logger) *SlogHandler {
+	return &SlogHandler{
+		logger: Structured(logger),
+	}
+}
+
+func slogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TraceLevel
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	case level <= slog.LevelError:
+		return ErrorLevel
+	}
+	return FatalLevel
+}
+
+// appendAttr flattens groups into key value pairs, empty attributes are ignored
+func appendAttr(keysAndValues []interface{}, prefix string, attr slog.Attr) []interface{} {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			keysAndValues = appendAttr(keysAndValues, prefix, groupAttr)
+		}
+		return keysAndValues
+	}
+	return append(keysAndValues, prefix+attr.Key, attr.Value.Any())
+}
+
+// Enabled implements slog.Handler
+func (s *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.logger.Enabled(slogLevel(level))
+}
+
+// Handle implements slog.Handler
+func (s *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	keysAndValues := make([]interface{}, 0, record.NumAttrs()*2)
+	record.Attrs(func(attr slog.Attr) bool {
+		keysAndValues = appendAttr(keysAndValues, s.prefix, attr)
+		return true
+	})
+	s.logger.Log(slogLevel(record.Level), record.Message, keysAndValues...)
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (s *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var keysAndValues []interface{}
+	for _, attr := range attrs {
+		keysAndValues = appendAttr(keysAndValues, s.prefix, attr)
+	}
+	logger := s.logger
+	for i := 0; i < len(keysAndValues); i += 2 {
+		logger = logger.With(keysAndValues[i].(string), keysAndValues[i+1])
+	}
+	return &SlogHandler{
+		logger: logger,
+		prefix: s.prefix,
+	}
+}
+
+// WithGroup implements slog.Handler
+func (s *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	return &SlogHandler{
+		logger: s.logger,
+		prefix: s.prefix + name + ".",
+	}
+}
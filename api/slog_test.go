@@ -0,0 +1,32 @@
+// +build go1.21
+
+package api_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var logger, withLogger mocks.StructuredLogger
+	logger.On("Enabled", api.DebugLevel).Return(false)
+	logger.On("Enabled", api.WarnLevel).Return(true)
+	logger.On("With", "request.id", "mockRequestID").Return(&withLogger)
+	withLogger.On("Enabled", api.WarnLevel).Return(true)
+	withLogger.On("Log", api.WarnLevel, "slow request", "request.duration", int64(10), "request.user.name", "mockUser")
+	log := slog.New(api.NewSlogHandler(&logger))
+	log.Debug("not sent", "key", "value")
+	log.WithGroup("request").With("id", "mockRequestID").WarnContext(
+		context.Background(),
+		"slow request",
+		"duration", 10,
+		slog.Group("user", "name", "mockUser"),
+	)
+	logger.AssertNotCalled(t, "Log", mock.Anything, mock.Anything)
+	withLogger.AssertExpectations(t)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// Schedule of timer trigger
+type Schedule struct {
+	// AdjustForDST is true if schedule is adjusted for daylight saving time
+	AdjustForDST bool
+}
+
+// ScheduleStatus of timer trigger
+type ScheduleStatus struct {
+	// Last is a time of the last recorded occurrence of schedule
+	Last time.Time
+	// Next is a time of the next expected occurrence of schedule
+	Next time.Time
+	// LastUpdated is a time of the last update of schedule status
+	LastUpdated time.Time
+}
+
+// TimerInfo represents timerTrigger in function definition.
+type TimerInfo struct {
+	// Schedule of timer
+	Schedule Schedule
+	// ScheduleStatus is nil if host does not monitor schedule of timer, for instance
+	// when useMonitor is false in function.json
+	ScheduleStatus *ScheduleStatus
+	// IsPastDue is true if invocation is later than scheduled, for instance
+	// after function app restart
+	IsPastDue bool
+}
+
+type timerInfoJSON struct {
+	Schedule       Schedule
+	ScheduleStatus *struct {
+		Last        hostTime
+		Next        hostTime
+		LastUpdated hostTime
+	}
+	IsPastDue bool
+}
+
+// Unmarshal implements unmarshaler for api.TimerInfo
+func (t *TimerInfo) Unmarshal(data *rpc.TypedData) error {
+	var b []byte
+	switch td := data.Data.(type) {
+	case *rpc.TypedData_Json:
+		b = []byte(td.Json)
+	case *rpc.TypedData_String_:
+		b = []byte(td.String_)
+	case *rpc.TypedData_Bytes:
+		b = td.Bytes
+	default:
+		return errors.Errorf("not a timer trigger")
+	}
+	var timer timerInfoJSON
+	if err := json.Unmarshal(b, &timer); err != nil {
+		return errors.Wrap(err, "invalid timer trigger")
+	}
+	*t = TimerInfo{
+		Schedule:  timer.Schedule,
+		IsPastDue: timer.IsPastDue,
+	}
+	if status := timer.ScheduleStatus; status != nil {
+		t.ScheduleStatus = &ScheduleStatus{
+			Last:        time.Time(status.Last),
+			Next:        time.Time(status.Next),
+			LastUpdated: time.Time(status.LastUpdated),
+		}
+	}
+	return nil
+}
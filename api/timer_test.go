@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerInfoUnmarshal(t *testing.T) {
+	var timer api.TimerInfo
+	assert.NoError(t, timer.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: `{
+				"Schedule": {"AdjustForDST": true},
+				"ScheduleStatus": {
+					"Last": "0001-01-01T00:00:00",
+					"Next": "2020-03-04T10:20:00+01:00",
+					"LastUpdated": "2020-03-04T10:15:00.123Z"
+				},
+				"IsPastDue": true
+			}`,
+		},
+	}))
+	assert.True(t, timer.Schedule.AdjustForDST)
+	assert.True(t, timer.IsPastDue)
+	if assert.NotNil(t, timer.ScheduleStatus) {
+		assert.True(t, timer.ScheduleStatus.Last.IsZero())
+		assert.True(t, timer.ScheduleStatus.Next.Equal(time.Date(2020, 3, 4, 9, 20, 0, 0, time.UTC)))
+		assert.Equal(t, time.Date(2020, 3, 4, 10, 15, 0, 123000000, time.UTC), timer.ScheduleStatus.LastUpdated)
+	}
+}
+
+func TestTimerInfoUnmarshalWithoutScheduleStatus(t *testing.T) {
+	var timer api.TimerInfo
+	assert.NoError(t, timer.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_String_{
+			String_: `{"Schedule":{},"ScheduleStatus":null,"IsPastDue":false}`,
+		},
+	}))
+	assert.Equal(t, api.TimerInfo{}, timer)
+	assert.EqualError(t, timer.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: `{"ScheduleStatus":{"Next":"tomorrow"}}`,
+		},
+	}), `invalid timer trigger: invalid time tomorrow: parsing time "tomorrow" as "2006-01-02T15:04:05.999999999": cannot parse "tomorrow" as "2006"`)
+	assert.EqualError(t, timer.Unmarshal(&rpc.TypedData{
+		Data: &rpc.TypedData_Int{Int: 1},
+	}), "not a timer trigger")
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// W3C trace context headers
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// TraceID is a W3C trace id
+type TraceID [16]byte
+
+// String returns trace id as lowercase hex
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid returns false if trace id is all zeros
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID is a W3C parent id
+type SpanID [8]byte
+
+// String returns span id as lowercase hex
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid returns false if span id is all zeros
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// TraceFlags of W3C trace context
+type TraceFlags byte
+
+// FlagsSampled is set if caller may have recorded trace
+const FlagsSampled TraceFlags = 0x01
+
+// TraceContext is a W3C trace context of invocation started by host
+type TraceContext struct {
+	TraceID TraceID
+	// SpanID is the id of host span that invoked function
+	SpanID     SpanID
+	TraceFlags TraceFlags
+	// TraceState is a raw value of tracestate header
+	TraceState string
+	// Attributes are tags of host activity
+	Attributes map[string]string
+}
+
+// IsValid returns true if trace context has a valid trace id and span id
+func (t TraceContext) IsValid() bool {
+	return t.TraceID.IsValid() && t.SpanID.IsValid()
+}
+
+// IsSampled returns true if sampled flag is set
+func (t TraceContext) IsSampled() bool {
+	return t.TraceFlags&FlagsSampled != 0
+}
+
+// TraceParent returns trace context formatted as traceparent header value
+func (t TraceContext) TraceParent() string {
+	return "00-" + t.TraceID.String() + "-" + t.SpanID.String() + "-" + hex.EncodeToString([]byte{byte(t.TraceFlags)})
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return errors.Errorf("invalid length or case of %s", s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// ParseTraceParent parses traceparent header value as defined by W3C Trace Context
+func ParseTraceParent(traceParent string) (tc TraceContext, err error) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 {
+		return tc, errors.Errorf("invalid traceparent %s", traceParent)
+	}
+	var version [1]byte
+	var flags [1]byte
+	err = decodeHex(version[:], parts[0])
+	if err == nil && (version[0] == 0xff || (version[0] == 0 && len(parts) != 4)) {
+		err = errors.Errorf("unsupported version")
+	}
+	if err == nil {
+		err = decodeHex(tc.TraceID[:], parts[1])
+	}
+	if err == nil {
+		err = decodeHex(tc.SpanID[:], parts[2])
+	}
+	if err == nil {
+		err = decodeHex(flags[:], parts[3])
+	}
+	if err == nil && !tc.IsValid() {
+		err = errors.Errorf("zero trace id or span id")
+	}
+	if err != nil {
+		return TraceContext{}, errors.Wrapf(err, "invalid traceparent %s", traceParent)
+	}
+	tc.TraceFlags = TraceFlags(flags[0])
+	return tc, nil
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns context with trace context
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// GetTraceContext returns trace context of invocation. Returns false if host did not
+// send a valid trace context.
+func GetTraceContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
+
+// TextMapCarrier carries propagated values, it has the same methods as
+// OpenTelemetry propagation.TextMapCarrier
+type TextMapCarrier interface {
+	Get(key string) string
+	Set(key string, value string)
+	Keys() []string
+}
+
+// HeaderCarrier adapts http.Header to TextMapCarrier
+type HeaderCarrier http.Header
+
+// Get returns value of header
+func (h HeaderCarrier) Get(key string) string {
+	return http.Header(h).Get(key)
+}
+
+// Set sets value of header
+func (h HeaderCarrier) Set(key string, value string) {
+	http.Header(h).Set(key, value)
+}
+
+// Keys returns header names
+func (h HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// TraceContextPropagator propagates trace context using W3C traceparent and tracestate
+// headers. It has the same methods as OpenTelemetry propagation.TraceContext, so with
+// a small adapter it can be used as OpenTelemetry propagator.
+//
+//  req, _ := http.NewRequest(http.MethodGet, url, nil)
+//  api.TraceContextPropagator{}.Inject(ctx, api.HeaderCarrier(req.Header))
+type TraceContextPropagator struct{}
+
+// Inject sets trace context headers in carrier if ctx has a valid trace context
+func (TraceContextPropagator) Inject(ctx context.Context, carrier TextMapCarrier) {
+	tc, ok := GetTraceContext(ctx)
+	if !ok {
+		return
+	}
+	carrier.Set(TraceParentHeader, tc.TraceParent())
+	if tc.TraceState != "" {
+		carrier.Set(TraceStateHeader, tc.TraceState)
+	}
+}
+
+// Extract returns context with trace context read from carrier. If carrier does not
+// have a valid trace context, ctx is returned.
+func (TraceContextPropagator) Extract(ctx context.Context, carrier TextMapCarrier) context.Context {
+	tc, err := ParseTraceParent(carrier.Get(TraceParentHeader))
+	if err != nil {
+		return ctx
+	}
+	tc.TraceState = carrier.Get(TraceStateHeader)
+	return ContextWithTraceContext(ctx, tc)
+}
+
+// Fields returns headers used by propagator
+func (TraceContextPropagator) Fields() []string {
+	return []string{TraceParentHeader, TraceStateHeader}
+}
+
+// TraceTransport is a http.RoundTripper that propagates trace context of request
+// context, so that outgoing calls continue trace of invocation.
+//
+//  client := &http.Client{Transport: &api.TraceTransport{}}
+//  req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+//  resp, err := client.Do(req)
+type TraceTransport struct {
+	// Base transport, defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := GetTraceContext(req.Context()); ok {
+		// RoundTripper must not modify request
+		req = req.Clone(req.Context())
+		TraceContextPropagator{}.Inject(req.Context(), HeaderCarrier(req.Header))
+	}
+	return base.RoundTrip(req)
+}
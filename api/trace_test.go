@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/stretchr/testify/assert"
+)
+
+const mockTraceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+func TestParseTraceParent(t *testing.T) {
+	tc, err := api.ParseTraceParent(mockTraceParent)
+	assert.NoError(t, err)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", tc.TraceID.String())
+	assert.Equal(t, "b7ad6b7169203331", tc.SpanID.String())
+	assert.True(t, tc.IsSampled())
+	assert.Equal(t, mockTraceParent, tc.TraceParent())
+	// future versions may have additional fields
+	_, err = api.ParseTraceParent("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00-extra")
+	assert.NoError(t, err)
+	for _, invalid := range []string{
+		"",
+		"|legacy-activity-id.1.",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b716920333-01",
+	} {
+		_, err := api.ParseTraceParent(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTraceContextPropagator(t *testing.T) {
+	var propagator api.TraceContextPropagator
+	header := http.Header{}
+	header.Set("Traceparent", mockTraceParent)
+	header.Set("Tracestate", "congo=t61rcWkgMzE")
+	ctx := propagator.Extract(context.Background(), api.HeaderCarrier(header))
+	tc, ok := api.GetTraceContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "congo=t61rcWkgMzE", tc.TraceState)
+	out := http.Header{}
+	propagator.Inject(ctx, api.HeaderCarrier(out))
+	assert.Equal(t, header, out)
+	assert.Equal(t, []string{"traceparent", "tracestate"}, propagator.Fields())
+	_, ok = api.GetTraceContext(context.Background())
+	assert.False(t, ok)
+	empty := http.Header{}
+	propagator.Inject(context.Background(), api.HeaderCarrier(empty))
+	assert.Empty(t, empty)
+}
+
+func TestTraceTransport(t *testing.T) {
+	received := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer srv.Close()
+	tc, err := api.ParseTraceParent(mockTraceParent)
+	assert.NoError(t, err)
+	ctx := api.ContextWithTraceContext(context.Background(), tc)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: &api.TraceTransport{}}
+	resp, err := client.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	assert.Equal(t, mockTraceParent, (<-received).Get("traceparent"))
+	assert.Empty(t, req.Header.Get("traceparent"))
+}
@@ -35,6 +35,12 @@
 //      "HttpTrigger.Function": reflect.TypeOf(Function),
 //    })
 //  }
+//
+// Services injected into functions are registered in a registry passed with WithServices:
+//
+//  services := api.NewServices()
+//  services.Register(db)
+//  userworker.Execute(functions, userworker.WithServices(services))
 package userworker
 
 import (
@@ -44,19 +50,12 @@ import (
 	"reflect"
 
 	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/config"
 	"github.com/graphql-editor/azure-functions-golang-worker/pluginloader"
 	"github.com/graphql-editor/azure-functions-golang-worker/worker"
 	"github.com/pkg/errors"
 )
 
-var (
-	host                 = flag.String("host", "", "listend address, required")
-	port                 = flag.String("port", "", "listend port, required")
-	workerID             = flag.String("workerId", "", "worker id, required")
-	requestID            = flag.String("requestId", "", "request id, required")
-	grpcMaxMessageLength = flag.Int("grpcMaxMessageLength", 0, "grpc message lenght limit, required")
-)
-
 type localLoader map[string]reflect.Type
 
 func (l localLoader) GetFunctionType(fi worker.FunctionInfo, logger api.Logger) (reflect.Type, error) {
@@ -67,28 +66,71 @@ func (l localLoader) GetFunctionType(fi worker.FunctionInfo, logger api.Logger)
 	return t, nil
 }
 
+// Option configures worker started by Execute
+type Option func(*worker.Worker)
+
+// WithMiddleware adds middleware wrapping invocations of all functions
+func WithMiddleware(middleware ...worker.Middleware) Option {
+	return func(w *worker.Worker) {
+		w.Middleware = append(w.Middleware, middleware...)
+	}
+}
+
+// WithFunctionMiddleware adds middleware wrapping invocations of function with name
+func WithFunctionMiddleware(name string, middleware ...worker.Middleware) Option {
+	return func(w *worker.Worker) {
+		if w.FunctionMiddleware == nil {
+			w.FunctionMiddleware = make(map[string][]worker.Middleware)
+		}
+		w.FunctionMiddleware[name] = append(w.FunctionMiddleware[name], middleware...)
+	}
+}
+
+// WithServices sets services injected into fields of functions tagged with `azfunc:"inject"`
+func WithServices(services *api.Services) Option {
+	return func(w *worker.Worker) {
+		w.Loader.Services = services
+	}
+}
+
 // Execute worker with functions defined manually by user.
-func Execute(functions map[string]reflect.Type) {
-	flag.Parse()
-	if *host == "" || *port == "" || *workerID == "" || *requestID == "" || *grpcMaxMessageLength == 0 {
+func Execute(functions map[string]reflect.Type, options ...Option) {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
 		flag.Usage()
 		os.Exit(2)
 	}
+	opts, err := cfg.EventStreamOptions()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(2)
+	}
 	loader := pluginloader.NewLoader()
 	defer loader.Close()
 
 	w := worker.Worker{
-		WorkerID:  *workerID,
-		RequestID: *requestID,
-		Stream: worker.NewEventStream(
-			worker.HostEventStreamOption(*host),
-			worker.PortEventStreamOption(*port),
-		),
+		WorkerID:               cfg.WorkerID,
+		RequestID:              cfg.RequestID,
+		Stream:                 worker.NewEventStream(opts...),
+		LogQueueSize:           cfg.LogQueue.Size,
+		LogQueuePolicy:         cfg.LogQueue.Policy(),
+		DebugAddress:           cfg.DebugAddress,
+		MetricsFile:            cfg.MetricsFile,
+		MaxConcurrency:         cfg.MaxConcurrency,
+		MaxFunctionConcurrency: cfg.MaxFunctionConcurrency,
+		Health:                 cfg.Health.Health(),
 		Loader: worker.Loader{
 			TypeLoader:      localLoader(functions),
 			LoadedFunctions: make(map[string]worker.Function),
 		},
 	}
+	for _, option := range options {
+		option(&w)
+	}
 
 	if err := w.Listen(); err != nil {
 		fmt.Printf("%v\n", err)
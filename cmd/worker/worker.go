@@ -5,34 +5,40 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/config"
 	"github.com/graphql-editor/azure-functions-golang-worker/pluginloader"
 	"github.com/graphql-editor/azure-functions-golang-worker/worker"
 )
 
-var (
-	host                 = flag.String("host", "", "listend address, required")
-	port                 = flag.String("port", "", "listend port, required")
-	workerID             = flag.String("workerId", "", "worker id, required")
-	requestID            = flag.String("requestId", "", "request id, required")
-	grpcMaxMessageLength = flag.Int("grpcMaxMessageLength", 0, "grpc message lenght limit, required")
-)
-
 func main() {
-	flag.Parse()
-	if *host == "" || *port == "" || *workerID == "" || *requestID == "" || *grpcMaxMessageLength == 0 {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
 		flag.Usage()
 		os.Exit(2)
 	}
+	opts, err := cfg.EventStreamOptions()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(2)
+	}
+	// loader is closed by worker on exit
 	loader := pluginloader.NewLoader()
-	defer loader.Close()
 
 	w := worker.Worker{
-		WorkerID:  *workerID,
-		RequestID: *requestID,
-		Stream: worker.NewEventStream(
-			worker.HostEventStreamOption(*host),
-			worker.PortEventStreamOption(*port),
-		),
+		WorkerID:               cfg.WorkerID,
+		RequestID:              cfg.RequestID,
+		Stream:                 worker.NewEventStream(opts...),
+		LogQueueSize:           cfg.LogQueue.Size,
+		LogQueuePolicy:         cfg.LogQueue.Policy(),
+		DebugAddress:           cfg.DebugAddress,
+		MetricsFile:            cfg.MetricsFile,
+		MaxConcurrency:         cfg.MaxConcurrency,
+		MaxFunctionConcurrency: cfg.MaxFunctionConcurrency,
+		Health:                 cfg.Health.Health(),
 		Loader: worker.Loader{
 			TypeLoader:      loader,
 			LoadedFunctions: make(map[string]worker.Function),
@@ -0,0 +1,365 @@
+// Package config reads worker configuration shared by worker commands.
+//
+// Each setting can be defined in a json config file, an environment variable
+// or a command line flag. Flags take precedence over environment variables,
+// which take precedence over config file. Config file path is read from -config
+// flag or GOLANG_WORKER_CONFIG environment variable.
+//
+// Example config file:
+//  {
+//  	"dialTimeout": "10s",
+//  	"keepalive": {
+//  		"time": "1m",
+//  		"timeout": "20s"
+//  	},
+//  	"reconnect": {
+//  		"maxAttempts": 5,
+//  		"initialBackoff": "100ms",
+//  		"maxBackoff": "10s"
+//  	},
+//  	"maxConcurrency": 100,
+//  	"health": {
+//  		"maxPanics": 5,
+//  		"panicWindow": "1m"
+//  	}
+//  }
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// EnvPrefix is a prefix of environment variables read by Load
+const EnvPrefix = "GOLANG_WORKER_"
+
+// Duration that can be read from json as a string accepted by time.ParseDuration or
+// as a number of nanoseconds
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch vv := v.(type) {
+	case float64:
+		*d = Duration(vv)
+	case string:
+		dur, err := time.ParseDuration(vv)
+		if err != nil {
+			return err
+		}
+		*d = Duration(dur)
+	default:
+		return errors.Errorf("invalid duration %s", string(b))
+	}
+	return nil
+}
+
+// Keepalive configures keepalive pings sent to host
+type Keepalive struct {
+	// Time after which worker pings host if there is no activity. Keepalive is disabled if not set.
+	Time Duration `json:"time"`
+	// Timeout after ping after which connection is closed
+	Timeout             Duration `json:"timeout"`
+	PermitWithoutStream bool     `json:"permitWithoutStream"`
+}
+
+// TLS configures secure connection to host
+type TLS struct {
+	Enabled bool `json:"enabled"`
+	// CAFile with PEM encoded certificates used to verify host, system pool is used if not set
+	CAFile string `json:"caFile"`
+	// CertFile and KeyFile with PEM encoded client certificate
+	CertFile           string `json:"certFile"`
+	KeyFile            string `json:"keyFile"`
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// Reconnect configures worker.ReconnectPolicy
+type Reconnect struct {
+	MaxAttempts    int      `json:"maxAttempts"`
+	InitialBackoff Duration `json:"initialBackoff"`
+	MaxBackoff     Duration `json:"maxBackoff"`
+	Multiplier     float64  `json:"multiplier"`
+}
+
+// LogQueue configures queue of logs sent asynchronously to host
+type LogQueue struct {
+	// Size of queue, defaults to worker.DefaultLogQueueSize
+	Size int `json:"size"`
+	// DropOnFull drops logs when queue is full instead of blocking logger
+	DropOnFull bool `json:"dropOnFull"`
+}
+
+// Policy returns worker.LogQueuePolicy of queue
+func (l LogQueue) Policy() worker.LogQueuePolicy {
+	if l.DropOnFull {
+		return worker.DropOnFullLogQueue
+	}
+	return worker.BlockOnFullLogQueue
+}
+
+// Health configures worker.Health
+type Health struct {
+	// StuckAfter is the time after which running invocation is considered stuck,
+	// defaults to worker.DefaultStuckInvocationThreshold
+	StuckAfter Duration `json:"stuckAfter"`
+	// MaxPanics after which worker restarts, restart on panics is disabled if not set
+	MaxPanics int `json:"maxPanics"`
+	// PanicWindow in which panics are counted, all panics are counted if not set
+	PanicWindow Duration `json:"panicWindow"`
+}
+
+// Health returns worker.Health with configured thresholds
+func (h Health) Health() *worker.Health {
+	return &worker.Health{
+		StuckAfter:  time.Duration(h.StuckAfter),
+		MaxPanics:   h.MaxPanics,
+		PanicWindow: time.Duration(h.PanicWindow),
+	}
+}
+
+// Config of worker
+type Config struct {
+	Host                 string    `json:"host"`
+	Port                 string    `json:"port"`
+	WorkerID             string    `json:"workerId"`
+	RequestID            string    `json:"requestId"`
+	GrpcMaxMessageLength int       `json:"grpcMaxMessageLength"`
+	DialTimeout          Duration  `json:"dialTimeout"`
+	Keepalive            Keepalive `json:"keepalive"`
+	TLS                  TLS       `json:"tls"`
+	Reconnect            Reconnect `json:"reconnect"`
+	LogQueue             LogQueue  `json:"logQueue"`
+	// MaxConcurrency limits number of invocations running at the same time,
+	// defaults to worker.DefaultMaxConcurrency
+	MaxConcurrency int `json:"maxConcurrency"`
+	// MaxFunctionConcurrency limits number of invocations of a single function running
+	// at the same time, defaults to MaxConcurrency
+	MaxFunctionConcurrency int    `json:"maxFunctionConcurrency"`
+	Health                 Health `json:"health"`
+	// DebugAddress is a local address on which worker serves status and metrics
+	DebugAddress string `json:"debugAddress"`
+	// MetricsFile to which worker writes metrics on exit and on SIGUSR1
+	MetricsFile string `json:"metricsFile"`
+}
+
+// Validate checks if settings required by host are set
+func (c Config) Validate() error {
+	if c.Host == "" || c.Port == "" || c.WorkerID == "" || c.RequestID == "" || c.GrpcMaxMessageLength == 0 {
+		return errors.Errorf("host, port, workerId, requestId and grpcMaxMessageLength are required")
+	}
+	return nil
+}
+
+func (c Config) credentials() (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.TLS.ServerName,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+	}
+	if c.TLS.CAFile != "" {
+		b, err := ioutil.ReadFile(c.TLS.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read tls ca file")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("no certificates found in %s", c.TLS.CAFile)
+		}
+	}
+	if c.TLS.CertFile != "" || c.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read tls client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// EventStreamOptions returns options for worker.NewEventStream
+func (c Config) EventStreamOptions() ([]worker.EventStreamOption, error) {
+	opts := []worker.EventStreamOption{
+		worker.HostEventStreamOption(c.Host),
+		worker.PortEventStreamOption(c.Port),
+		worker.MaxGrpcMessageLengthEventStreamOption(c.GrpcMaxMessageLength),
+		worker.DialTimeoutEventStreamOption(c.DialTimeout),
+		worker.ReconnectPolicy{
+			MaxAttempts:    c.Reconnect.MaxAttempts,
+			InitialBackoff: time.Duration(c.Reconnect.InitialBackoff),
+			MaxBackoff:     time.Duration(c.Reconnect.MaxBackoff),
+			Multiplier:     c.Reconnect.Multiplier,
+		},
+	}
+	if c.Keepalive.Time > 0 {
+		opts = append(opts, worker.KeepaliveEventStreamOption(keepalive.ClientParameters{
+			Time:                time.Duration(c.Keepalive.Time),
+			Timeout:             time.Duration(c.Keepalive.Timeout),
+			PermitWithoutStream: c.Keepalive.PermitWithoutStream,
+		}))
+	}
+	if c.TLS.Enabled {
+		creds, err := c.credentials()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, worker.CredentialsEventStreamOption{TransportCredentials: creds})
+	}
+	return opts, nil
+}
+
+type stringValue struct{ p *string }
+
+func (s stringValue) Set(v string) error { *s.p = v; return nil }
+func (s stringValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	return *s.p
+}
+
+type intValue struct{ p *int }
+
+func (i intValue) Set(v string) (err error) { *i.p, err = strconv.Atoi(v); return }
+func (i intValue) String() string {
+	if i.p == nil {
+		return "0"
+	}
+	return strconv.Itoa(*i.p)
+}
+
+type floatValue struct{ p *float64 }
+
+func (f floatValue) Set(v string) (err error) { *f.p, err = strconv.ParseFloat(v, 64); return }
+func (f floatValue) String() string {
+	if f.p == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(*f.p, 'g', -1, 64)
+}
+
+type boolValue struct{ p *bool }
+
+func (b boolValue) Set(v string) (err error) { *b.p, err = strconv.ParseBool(v); return }
+func (b boolValue) IsBoolFlag() bool         { return true }
+func (b boolValue) String() string {
+	if b.p == nil {
+		return "false"
+	}
+	return strconv.FormatBool(*b.p)
+}
+
+type durationValue struct{ p *Duration }
+
+func (d durationValue) Set(v string) error {
+	dur, err := time.ParseDuration(v)
+	if err == nil {
+		*d.p = Duration(dur)
+	}
+	return err
+}
+func (d durationValue) String() string {
+	if d.p == nil {
+		return "0s"
+	}
+	return time.Duration(*d.p).String()
+}
+
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value func(c *Config) flag.Value
+}
+
+var settings = []setting{
+	{"host", "HOST", "listend address, required", func(c *Config) flag.Value { return stringValue{&c.Host} }},
+	{"port", "PORT", "listend port, required", func(c *Config) flag.Value { return stringValue{&c.Port} }},
+	{"workerId", "WORKER_ID", "worker id, required", func(c *Config) flag.Value { return stringValue{&c.WorkerID} }},
+	{"requestId", "REQUEST_ID", "request id, required", func(c *Config) flag.Value { return stringValue{&c.RequestID} }},
+	{"grpcMaxMessageLength", "GRPC_MAX_MESSAGE_LENGTH", "grpc message lenght limit, required", func(c *Config) flag.Value { return intValue{&c.GrpcMaxMessageLength} }},
+	{"dialTimeout", "DIAL_TIMEOUT", "time to wait for connection to host", func(c *Config) flag.Value { return durationValue{&c.DialTimeout} }},
+	{"keepaliveTime", "KEEPALIVE_TIME", "time after which worker pings host if there is no activity, keepalive is disabled if not set", func(c *Config) flag.Value { return durationValue{&c.Keepalive.Time} }},
+	{"keepaliveTimeout", "KEEPALIVE_TIMEOUT", "time to wait for keepalive ping response", func(c *Config) flag.Value { return durationValue{&c.Keepalive.Timeout} }},
+	{"keepalivePermitWithoutStream", "KEEPALIVE_PERMIT_WITHOUT_STREAM", "send keepalive pings without active stream", func(c *Config) flag.Value { return boolValue{&c.Keepalive.PermitWithoutStream} }},
+	{"tls", "TLS", "use tls connection to host", func(c *Config) flag.Value { return boolValue{&c.TLS.Enabled} }},
+	{"tlsCAFile", "TLS_CA_FILE", "file with PEM encoded certificates used to verify host", func(c *Config) flag.Value { return stringValue{&c.TLS.CAFile} }},
+	{"tlsCertFile", "TLS_CERT_FILE", "file with PEM encoded client certificate", func(c *Config) flag.Value { return stringValue{&c.TLS.CertFile} }},
+	{"tlsKeyFile", "TLS_KEY_FILE", "file with PEM encoded client certificate key", func(c *Config) flag.Value { return stringValue{&c.TLS.KeyFile} }},
+	{"tlsServerName", "TLS_SERVER_NAME", "server name used to verify host certificate", func(c *Config) flag.Value { return stringValue{&c.TLS.ServerName} }},
+	{"tlsInsecureSkipVerify", "TLS_INSECURE_SKIP_VERIFY", "do not verify host certificate", func(c *Config) flag.Value { return boolValue{&c.TLS.InsecureSkipVerify} }},
+	{"reconnectMaxAttempts", "RECONNECT_MAX_ATTEMPTS", "number of reconnect attempts, reconnect is disabled if 0 and unlimited if negative", func(c *Config) flag.Value { return intValue{&c.Reconnect.MaxAttempts} }},
+	{"reconnectInitialBackoff", "RECONNECT_INITIAL_BACKOFF", "time to wait before first reconnect attempt", func(c *Config) flag.Value { return durationValue{&c.Reconnect.InitialBackoff} }},
+	{"reconnectMaxBackoff", "RECONNECT_MAX_BACKOFF", "max time between reconnect attempts", func(c *Config) flag.Value { return durationValue{&c.Reconnect.MaxBackoff} }},
+	{"reconnectMultiplier", "RECONNECT_MULTIPLIER", "multiplier by which time between reconnect attempts grows", func(c *Config) flag.Value { return floatValue{&c.Reconnect.Multiplier} }},
+	{"logQueueSize", "LOG_QUEUE_SIZE", "number of logs waiting to be sent to host", func(c *Config) flag.Value { return intValue{&c.LogQueue.Size} }},
+	{"debugAddress", "DEBUG_ADDRESS", "local address on which worker serves /debug/status and /metrics", func(c *Config) flag.Value { return stringValue{&c.DebugAddress} }},
+	{"metricsFile", "METRICS_FILE", "file to which metrics are written on exit and on SIGUSR1", func(c *Config) flag.Value { return stringValue{&c.MetricsFile} }},
+	{"logQueueDropOnFull", "LOG_QUEUE_DROP_ON_FULL", "drop logs when log queue is full instead of waiting", func(c *Config) flag.Value { return boolValue{&c.LogQueue.DropOnFull} }},
+	{"maxConcurrency", "MAX_CONCURRENCY", "number of invocations running at the same time", func(c *Config) flag.Value { return intValue{&c.MaxConcurrency} }},
+	{"maxFunctionConcurrency", "MAX_FUNCTION_CONCURRENCY", "number of invocations of a single function running at the same time", func(c *Config) flag.Value { return intValue{&c.MaxFunctionConcurrency} }},
+	{"stuckAfter", "STUCK_AFTER", "time after which running invocation is reported as stuck", func(c *Config) flag.Value { return durationValue{&c.Health.StuckAfter} }},
+	{"maxPanics", "MAX_PANICS", "number of panics in functions after which worker restarts, restart on panics is disabled if not set", func(c *Config) flag.Value { return intValue{&c.Health.MaxPanics} }},
+	{"panicWindow", "PANIC_WINDOW", "period in which panics are counted, all panics are counted if not set", func(c *Config) flag.Value { return durationValue{&c.Health.PanicWindow} }},
+}
+
+// Load registers config flags on flag set, parses args and reads config
+// from file, environment and flags
+func Load(fs *flag.FlagSet, args []string) (c Config, err error) {
+	var flags Config
+	for _, s := range settings {
+		fs.Var(s.value(&flags), s.flag, s.usage)
+	}
+	configFile := fs.String("config", "", "path to json config file")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	path, ok := os.LookupEnv(EnvPrefix + "CONFIG")
+	if *configFile != "" {
+		path, ok = *configFile, true
+	}
+	if ok && path != "" {
+		var b []byte
+		if b, err = ioutil.ReadFile(path); err != nil {
+			return
+		}
+		if err = json.Unmarshal(b, &c); err != nil {
+			err = errors.Wrapf(err, "could not read config file %s", path)
+			return
+		}
+	}
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(EnvPrefix + s.env); ok {
+			if err = s.value(&c).Set(v); err != nil {
+				err = errors.Wrapf(err, "invalid value of %s", EnvPrefix+s.env)
+				return
+			}
+		}
+	}
+	visited := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
+	for _, s := range settings {
+		if visited[s.flag] {
+			// value was already validated by fs.Parse
+			s.value(&c).Set(s.value(&flags).String())
+		}
+	}
+	return
+}
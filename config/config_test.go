@@ -0,0 +1,117 @@
+package config_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/config"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "worker.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{
+		"host": "file-host",
+		"port": "1",
+		"dialTimeout": "10s",
+		"keepalive": {
+			"time": "1m",
+			"timeout": 20000000000
+		},
+		"reconnect": {
+			"maxAttempts": 3,
+			"multiplier": 1.5
+		},
+		"maxConcurrency": 10,
+		"health": {
+			"maxPanics": 3,
+			"panicWindow": "1m"
+		}
+	}`), 0644))
+	os.Setenv("GOLANG_WORKER_CONFIG", path)
+	os.Setenv("GOLANG_WORKER_PORT", "2")
+	os.Setenv("GOLANG_WORKER_RECONNECT_MAX_ATTEMPTS", "4")
+	os.Setenv("GOLANG_WORKER_MAX_FUNCTION_CONCURRENCY", "2")
+	os.Setenv("GOLANG_WORKER_MAX_PANICS", "4")
+	defer func() {
+		os.Unsetenv("GOLANG_WORKER_CONFIG")
+		os.Unsetenv("GOLANG_WORKER_PORT")
+		os.Unsetenv("GOLANG_WORKER_RECONNECT_MAX_ATTEMPTS")
+		os.Unsetenv("GOLANG_WORKER_MAX_FUNCTION_CONCURRENCY")
+		os.Unsetenv("GOLANG_WORKER_MAX_PANICS")
+	}()
+	cfg, err := config.Load(flag.NewFlagSet("worker", flag.ContinueOnError), []string{
+		"-workerId", "mockWorkerID",
+		"-requestId", "mockRequestID",
+		"-grpcMaxMessageLength", "2147483647",
+		"-reconnectMaxAttempts", "5",
+		"-tls",
+		"-logQueueDropOnFull",
+		"-stuckAfter", "30s",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, config.Config{
+		Host:                 "file-host",
+		Port:                 "2",
+		WorkerID:             "mockWorkerID",
+		RequestID:            "mockRequestID",
+		GrpcMaxMessageLength: 2147483647,
+		DialTimeout:          config.Duration(time.Second * 10),
+		Keepalive: config.Keepalive{
+			Time:    config.Duration(time.Minute),
+			Timeout: config.Duration(time.Second * 20),
+		},
+		TLS: config.TLS{
+			Enabled: true,
+		},
+		Reconnect: config.Reconnect{
+			MaxAttempts: 5,
+			Multiplier:  1.5,
+		},
+		LogQueue: config.LogQueue{
+			DropOnFull: true,
+		},
+		MaxConcurrency:         10,
+		MaxFunctionConcurrency: 2,
+		Health: config.Health{
+			StuckAfter:  config.Duration(time.Second * 30),
+			MaxPanics:   4,
+			PanicWindow: config.Duration(time.Minute),
+		},
+	}, cfg)
+	assert.Equal(t, worker.DropOnFullLogQueue, cfg.LogQueue.Policy())
+	health := cfg.Health.Health()
+	assert.Equal(t, time.Second*30, health.StuckAfter)
+	assert.Equal(t, 4, health.MaxPanics)
+	assert.Equal(t, time.Minute, health.PanicWindow)
+	opts, err := cfg.EventStreamOptions()
+	assert.NoError(t, err)
+	assert.Len(t, opts, 7)
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	os.Setenv("GOLANG_WORKER_DIAL_TIMEOUT", "10")
+	defer os.Unsetenv("GOLANG_WORKER_DIAL_TIMEOUT")
+	_, err := config.Load(flag.NewFlagSet("worker", flag.ContinueOnError), nil)
+	assert.Error(t, err)
+}
+
+func TestValidateRequiresHostSettings(t *testing.T) {
+	cfg, err := config.Load(flag.NewFlagSet("worker", flag.ContinueOnError), []string{
+		"-host", "127.0.0.1",
+		"-port", "1234",
+	})
+	assert.NoError(t, err)
+	assert.Error(t, cfg.Validate())
+}
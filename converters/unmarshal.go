@@ -7,3 +7,10 @@ type Unmarshaler interface {
 	// Unmarshal from rpc.TypedData
 	Unmarshal(*rpc.TypedData) error
 }
+
+// TriggerUnmarshaler interface is implemented by trigger types that, in addition to trigger data, read
+// trigger metadata sent by host. Trigger field implementing TriggerUnmarshaler is not unmarshaled with Unmarshaler.
+type TriggerUnmarshaler interface {
+	// UnmarshalTrigger from trigger rpc.TypedData and trigger metadata
+	UnmarshalTrigger(data *rpc.TypedData, metadata map[string]*rpc.TypedData) error
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+)
+
+// TimerTrigger is an example timerTrigger
+type TimerTrigger struct {
+	Timer api.TimerInfo `azfunc:"timerTrigger"`
+}
+
+// Run implements function behaviour
+func (t *TimerTrigger) Run(ctx context.Context, logger api.Logger) {
+	if t.Timer.IsPastDue {
+		logger.Warn("timer is running late")
+	}
+	if t.Timer.ScheduleStatus != nil {
+		logger.Infof("next run at %v", t.Timer.ScheduleStatus.Next)
+	}
+}
+
+// Function exports function entry point
+var Function TimerTrigger
@@ -0,0 +1,7 @@
+module function
+
+go 1.13
+
+replace github.com/graphql-editor/azure-functions-golang-worker => ../../
+
+require github.com/graphql-editor/azure-functions-golang-worker v0.0.0-00010101000000-000000000000 // indirect
@@ -0,0 +1,109 @@
+package function
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/converters"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// batchMetadataSuffix is a suffix of trigger metadata keys that hold metadata of each item in batch
+const batchMetadataSuffix = "Array"
+
+// jsonItem converts JSON array item to typed data, JSON strings are unquoted
+func jsonItem(raw json.RawMessage) *rpc.TypedData {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return &rpc.TypedData{Data: &rpc.TypedData_String_{String_: s}}
+	}
+	return &rpc.TypedData{Data: &rpc.TypedData_Json{Json: string(raw)}}
+}
+
+// batchItems splits collection or JSON array into items, returns false if data is not a batch
+func batchItems(data *rpc.TypedData) ([]*rpc.TypedData, bool) {
+	var items []*rpc.TypedData
+	switch td := data.GetData().(type) {
+	case *rpc.TypedData_CollectionString:
+		for _, s := range td.CollectionString.GetString_() {
+			items = append(items, &rpc.TypedData{Data: &rpc.TypedData_String_{String_: s}})
+		}
+	case *rpc.TypedData_CollectionBytes:
+		for _, b := range td.CollectionBytes.GetBytes() {
+			items = append(items, &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: b}})
+		}
+	case *rpc.TypedData_CollectionSint64:
+		for _, i := range td.CollectionSint64.GetSint64() {
+			items = append(items, &rpc.TypedData{Data: &rpc.TypedData_Int{Int: i}})
+		}
+	case *rpc.TypedData_CollectionDouble:
+		for _, d := range td.CollectionDouble.GetDouble() {
+			items = append(items, &rpc.TypedData{Data: &rpc.TypedData_Double{Double: d}})
+		}
+	case *rpc.TypedData_Json:
+		var raws []json.RawMessage
+		if err := json.Unmarshal([]byte(td.Json), &raws); err != nil {
+			return nil, false
+		}
+		for _, raw := range raws {
+			items = append(items, jsonItem(raw))
+		}
+	default:
+		return nil, false
+	}
+	return items, true
+}
+
+// splitBatch splits data and metadata of trigger with cardinality many into data and metadata
+// of each item. Metadata of item is read from metadata keys with Array suffix and stored
+// under a key without suffix, other metadata is shared by all items.
+func splitBatch(data *rpc.TypedData, metadata map[string]*rpc.TypedData) ([]*rpc.TypedData, []map[string]*rpc.TypedData, error) {
+	items, ok := batchItems(data)
+	if !ok {
+		return nil, nil, errors.Errorf("trigger data is not a batch")
+	}
+	itemsMetadata := make([]map[string]*rpc.TypedData, len(items))
+	for i := range itemsMetadata {
+		itemsMetadata[i] = make(map[string]*rpc.TypedData, len(metadata))
+	}
+	for k, v := range metadata {
+		var values []*rpc.TypedData
+		isBatch := false
+		if strings.HasSuffix(k, batchMetadataSuffix) {
+			values, isBatch = batchItems(v)
+		}
+		if !isBatch || len(values) != len(items) {
+			for i := range itemsMetadata {
+				itemsMetadata[i][k] = v
+			}
+			continue
+		}
+		key := strings.TrimSuffix(k, batchMetadataSuffix)
+		for i, value := range values {
+			itemsMetadata[i][key] = value
+		}
+	}
+	return items, itemsMetadata, nil
+}
+
+// newBatchUnmarshaler unmarshals batch into slice field, which element implements converters.TriggerUnmarshaler
+func newBatchUnmarshaler(f field, dataType string) triggerUnmarshaler {
+	return func(data *rpc.TypedData, metadata map[string]*rpc.TypedData, v reflect.Value) error {
+		items, itemsMetadata, err := splitBatch(data, metadata)
+		if err != nil {
+			return err
+		}
+		field, _ := getFieldValue(f, v)
+		slice := reflect.MakeSlice(f.typ, len(items), len(items))
+		for i, item := range items {
+			unmarshaler := slice.Index(i).Addr().Interface().(converters.TriggerUnmarshaler)
+			if err := unmarshaler.UnmarshalTrigger(withDataType(item, dataType), itemsMetadata[i]); err != nil {
+				return errors.Wrapf(err, "invalid batch item %d", i)
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+}
@@ -3,6 +3,7 @@ package function
 import (
 	"context"
 	"reflect"
+	"strings"
 
 	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/converters"
@@ -13,48 +14,71 @@ import (
 // Binding represents a named binding with type and direction
 type Binding struct {
 	Name, Type string
+	// DataType of binding from function.json, one of api.DataTypeString, api.DataTypeBinary,
+	// api.DataTypeStream or empty. Input data is converted to data type before it is unmarshaled.
+	DataType string
 }
 
 // Bindings list of bindings defined in function.json except for trigger and $return
 type Bindings []Binding
 
 type unmarshaler func(data *rpc.TypedData, v reflect.Value) error
+type triggerUnmarshaler func(data *rpc.TypedData, metadata map[string]*rpc.TypedData, v reflect.Value) error
 type marshaler func(v reflect.Value) (*rpc.TypedData, error)
 
 var (
-	functionInterfaceType       = reflect.TypeOf((*api.Function)(nil)).Elem()
-	returnFunctionInterfaceType = reflect.TypeOf((*api.ReturnFunction)(nil)).Elem()
-	stringMapOfAny              = reflect.TypeOf((*map[string]interface{})(nil)).Elem()
+	functionInterfaceType            = reflect.TypeOf((*api.Function)(nil)).Elem()
+	returnFunctionInterfaceType      = reflect.TypeOf((*api.ReturnFunction)(nil)).Elem()
+	errorFunctionInterfaceType       = reflect.TypeOf((*api.ErrorFunction)(nil)).Elem()
+	returnErrorFunctionInterfaceType = reflect.TypeOf((*api.ReturnErrorFunction)(nil)).Elem()
+	stringMapOfAny                   = reflect.TypeOf((*map[string]interface{})(nil)).Elem()
 )
 
+func implementsFunction(t reflect.Type) bool {
+	return t.Implements(functionInterfaceType) || t.Implements(errorFunctionInterfaceType)
+}
+
+func implementsReturnFunction(t reflect.Type) bool {
+	return t.Implements(returnFunctionInterfaceType) || t.Implements(returnErrorFunctionInterfaceType)
+}
+
 func isStructFunctionType(t reflect.Type) (bool, error) {
 	if t.Kind() != reflect.Struct {
 		return false, nil
 	}
-	ok := t.Implements(functionInterfaceType)
+	ok := implementsFunction(t)
 	if ok {
 		return false, errors.Errorf("Run method of %s must have a pointer reciever", t.Name())
 	}
-	return reflect.PtrTo(t).Implements(functionInterfaceType), nil
+	return implementsFunction(reflect.PtrTo(t)), nil
 }
 
 func isReturnStructFunctionType(t reflect.Type) (bool, error) {
 	if t.Kind() != reflect.Struct {
 		return false, nil
 	}
-	ok := t.Implements(returnFunctionInterfaceType)
+	ok := implementsReturnFunction(t)
 	if ok {
 		return false, errors.Errorf("Run method of %s must have a pointer reciever", t.Name())
 	}
-	return reflect.PtrTo(t).Implements(returnFunctionInterfaceType), nil
+	return implementsReturnFunction(reflect.PtrTo(t)), nil
 }
 
-// TriggerType of supported triggers
+// TriggerType of trigger binding. Binding types with a Trigger suffix are triggers,
+// exceptions can be registered with RegisterTriggerType.
 type TriggerType string
 
 const (
 	// HTTPTrigger represents httpTrigger defined in function.json
 	HTTPTrigger TriggerType = "httpTrigger"
+	// TimerTrigger represents timerTrigger defined in function.json
+	TimerTrigger TriggerType = "timerTrigger"
+	// QueueTrigger represents queueTrigger defined in function.json
+	QueueTrigger TriggerType = "queueTrigger"
+	// BlobTrigger represents blobTrigger defined in function.json
+	BlobTrigger TriggerType = "blobTrigger"
+	// ServiceBusTrigger represents serviceBusTrigger defined in function.json
+	ServiceBusTrigger TriggerType = "serviceBusTrigger"
 )
 
 type kind uint8
@@ -79,8 +103,8 @@ func getFunctionType(t reflect.Type, trigger TriggerType) (reflect.Type, kind, e
 	if ok {
 		return rt, returnStructFunction, err
 	}
-	if !t.Implements(functionInterfaceType) && !t.Implements(returnFunctionInterfaceType) {
-		return nil, invalid, errors.Errorf("type must implement either api.Function or api.ReturnFunction")
+	if !implementsFunction(t) && !implementsReturnFunction(t) {
+		return nil, invalid, errors.Errorf("type must implement one of api.Function, api.ReturnFunction, api.ErrorFunction or api.ReturnErrorFunction")
 	}
 	if rt.Kind() == reflect.Map && rt.ConvertibleTo(stringMapOfAny) {
 		return rt, mapFunction, nil
@@ -93,36 +117,85 @@ type ObjectType struct {
 	objectType         reflect.Type
 	kind               kind
 	triggerType        TriggerType
-	triggerUnmarshaler unmarshaler
+	triggerUnmarshaler triggerUnmarshaler
 	returnMarshaler    marshaler
 	inputUnmarshalers  map[string]unmarshaler
 	outputMarshalers   map[string]marshaler
 	httpOutBindings    []string
+	// prototype is a pointer to initialized object copied by New
+	prototype reflect.Value
+	injected  []injectedField
 }
 
-// NewObjectType creates new user function object type
+// NewObjectType creates new user function object type with trigger matched by trigger type
 func NewObjectType(
 	t reflect.Type,
 	trigger TriggerType,
 	inputBindings Bindings,
 	outputBindings Bindings,
 ) (ObjectType, error) {
-	tt, kind, err := getFunctionType(t, trigger)
+	return NewTriggerObjectType(t, Binding{Type: string(trigger)}, inputBindings, outputBindings)
+}
+
+var triggerUnmarshalerInterface = reflect.TypeOf((*converters.TriggerUnmarshaler)(nil)).Elem()
+
+// newTriggerUnmarshaler matches trigger by it's type and, for struct functions, falls back
+// to trigger binding name
+func newTriggerUnmarshaler(trigger Binding, t reflect.Type, kind kind) triggerUnmarshaler {
+	typeBinding := Binding{
+		Name:     trigger.Type,
+		Type:     trigger.Type,
+		DataType: trigger.DataType,
+	}
+	if kind == structFunction || kind == returnStructFunction {
+		f, ok := fieldByName(t, typeBinding.Name)
+		if !ok && trigger.Name != "" {
+			f, ok = fieldByName(t, trigger.Name)
+			typeBinding = trigger
+		}
+		if ok && reflect.PtrTo(f.typ).Implements(triggerUnmarshalerInterface) {
+			return func(data *rpc.TypedData, metadata map[string]*rpc.TypedData, v reflect.Value) error {
+				field, _ := getFieldValue(f, v)
+				return field.Addr().Interface().(converters.TriggerUnmarshaler).UnmarshalTrigger(withDataType(data, trigger.DataType), metadata)
+			}
+		}
+		if ok && f.typ.Kind() == reflect.Slice && reflect.PtrTo(f.typ.Elem()).Implements(triggerUnmarshalerInterface) {
+			return newBatchUnmarshaler(f, trigger.DataType)
+		}
+	}
+	u := newInputUnmarshaler(typeBinding, t, kind)
+	if u == nil {
+		return nil
+	}
+	return func(data *rpc.TypedData, metadata map[string]*rpc.TypedData, v reflect.Value) error {
+		return u(data, v)
+	}
+}
+
+// NewTriggerObjectType creates new user function object type. Trigger field of struct function
+// is matched by trigger type or, if there's no such field, by trigger binding name. Map functions
+// have trigger set under trigger type key.
+func NewTriggerObjectType(
+	t reflect.Type,
+	trigger Binding,
+	inputBindings Bindings,
+	outputBindings Bindings,
+) (ObjectType, error) {
+	triggerType := TriggerType(trigger.Type)
+	tt, kind, err := getFunctionType(t, triggerType)
 	if err != nil {
 		return ObjectType{}, err
 	}
 	objectType := ObjectType{
-		objectType:  tt,
-		kind:        kind,
-		triggerType: HTTPTrigger,
-		triggerUnmarshaler: newInputUnmarshaler(Binding{
-			Name: string(trigger),
-		}, tt, kind),
-		inputUnmarshalers: map[string]unmarshaler{},
-		outputMarshalers:  map[string]marshaler{},
-		httpOutBindings:   []string{},
-	}
-	if t.Implements(returnFunctionInterfaceType) {
+		objectType:         tt,
+		kind:               kind,
+		triggerType:        triggerType,
+		triggerUnmarshaler: newTriggerUnmarshaler(trigger, tt, kind),
+		inputUnmarshalers:  map[string]unmarshaler{},
+		outputMarshalers:   map[string]marshaler{},
+		httpOutBindings:    []string{},
+	}
+	if implementsReturnFunction(t) {
 		objectType.returnMarshaler = interfaceValueGet
 	}
 	for _, binding := range inputBindings {
@@ -148,7 +221,7 @@ func NewObjectType(
 			objectType.httpOutBindings = append(objectType.httpOutBindings, binding.Name)
 		}
 	}
-	if trigger == HTTPTrigger {
+	if strings.EqualFold(trigger.Type, string(HTTPTrigger)) {
 		objectType.httpOutBindings = append(objectType.httpOutBindings, "$return")
 	}
 	return objectType, nil
@@ -164,6 +237,10 @@ func (f *ObjectType) New() Object {
 	case reflect.Slice:
 		instance.Elem().Set(reflect.MakeSlice(t, 0, 0))
 	}
+	if f.prototype.IsValid() {
+		copyPrototype(instance, f.prototype)
+	}
+	f.inject(instance)
 	return Object{tp: f, instance: instance}
 }
 
@@ -174,30 +251,71 @@ type Object struct {
 	returnValue interface{}
 }
 
+// Interface returns pointer to user function object
+func (f *Object) Interface() interface{} {
+	return f.instance.Interface()
+}
+
 // BindingData for user defined bindings
 type BindingData struct {
 	Name string
 	Data *rpc.TypedData
 }
 
+// ErrTimeout is returned by Call if function did not return before context deadline
+var ErrTimeout = errors.New("function timed out")
+
 // Call user function object with bound data
+//
+// If context has a deadline, Call returns ErrTimeout as soon as the deadline passes, even if function
+// is still running. Object must not be used after timeout.
 func (f *Object) Call(
 	ctx context.Context,
 	logger api.Logger,
 	TriggerData *rpc.TypedData,
 	TriggerMetaData map[string]*rpc.TypedData,
 	inputBindings ...BindingData,
+) (err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return f.call(ctx, logger, TriggerData, TriggerMetaData, inputBindings...)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f.call(ctx, logger, TriggerData, TriggerMetaData, inputBindings...)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			// cancelled functions are expected to return on their own
+			return <-done
+		}
+		select {
+		case err = <-done:
+		default:
+			err = errors.WithStack(ErrTimeout)
+		}
+	}
+	return
+}
+
+func (f *Object) call(
+	ctx context.Context,
+	logger api.Logger,
+	TriggerData *rpc.TypedData,
+	TriggerMetaData map[string]*rpc.TypedData,
+	inputBindings ...BindingData,
 ) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = errors.Errorf("%#v", r)
+			err = NewPanicError(r)
 		}
 	}()
 	if TriggerData == nil {
 		err = errors.Errorf("missing trigger data")
 	}
 	if err == nil && f.tp.triggerUnmarshaler != nil {
-		err = f.tp.triggerUnmarshaler(TriggerData, f.instance)
+		err = f.tp.triggerUnmarshaler(TriggerData, TriggerMetaData, f.instance)
 	}
 	for _, bd := range inputBindings {
 		if err != nil {
@@ -223,6 +341,10 @@ func (f *Object) Call(
 			fn.Run(ctx, logger)
 		case api.ReturnFunction:
 			f.returnValue = fn.Run(ctx, logger)
+		case api.ErrorFunction:
+			err = fn.Run(ctx, logger)
+		case api.ReturnErrorFunction:
+			f.returnValue, err = fn.Run(ctx, logger)
 		}
 	}
 	return
@@ -254,7 +376,7 @@ func (f *Object) wrapHTTPOut(data *rpc.TypedData, name string) *rpc.TypedData {
 
 // ReturnValue returns marshaled function call return value
 func (f *Object) ReturnValue() (*rpc.TypedData, bool, error) {
-	_, ok := f.instance.Interface().(api.ReturnFunction)
+	ok := implementsReturnFunction(f.instance.Type())
 	if !ok {
 		return nil, ok, nil
 	}
@@ -3,14 +3,17 @@ package function_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	functionpkg "github.com/graphql-editor/azure-functions-golang-worker/function"
 	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -717,3 +720,135 @@ func TestOptionalBindings(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, copyBinding)
 }
+
+type HangingFunction struct {
+	HTTPTrigger *api.Request
+}
+
+func (f *HangingFunction) Run(ctx context.Context, logger api.Logger) {
+	select {}
+}
+
+func TestCallTimeout(t *testing.T) {
+	var function *HangingFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err = object.Call(
+		ctx,
+		&mocks.Logger{},
+		inputRPCHttpData,
+		nil,
+	)
+	assert.Equal(t, functionpkg.ErrTimeout, errors.Cause(err))
+}
+
+type PanickingFunction struct {
+	HTTPTrigger *api.Request
+}
+
+func (f *PanickingFunction) Run(ctx context.Context, logger api.Logger) {
+	panic(errors.New("function failed"))
+}
+
+func TestCallPanic(t *testing.T) {
+	var function *PanickingFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	err = object.Call(
+		context.Background(),
+		&mocks.Logger{},
+		inputRPCHttpData,
+		nil,
+	)
+	panicErr, ok := err.(*functionpkg.PanicError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "panic: function failed", panicErr.Error())
+		st := panicErr.StackTrace()
+		if assert.Len(t, st, 1) {
+			assert.Equal(t, "(*PanickingFunction).Run", fmt.Sprintf("%n", st[0]))
+		}
+	}
+}
+
+type ErrorFunction struct {
+	HTTPTrigger *api.Request
+}
+
+func (f *ErrorFunction) Run(ctx context.Context, logger api.Logger) error {
+	return errors.New("function failed")
+}
+
+func TestCallError(t *testing.T) {
+	var function *ErrorFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	err = object.Call(
+		context.Background(),
+		&mocks.Logger{},
+		inputRPCHttpData,
+		nil,
+	)
+	assert.EqualError(t, err, "function failed")
+	_, ok, err := object.ReturnValue()
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+type ReturnErrorFunction struct {
+	HTTPTrigger *api.Request
+}
+
+func (f *ReturnErrorFunction) Run(ctx context.Context, logger api.Logger) (interface{}, error) {
+	if f.HTTPTrigger.Method != "mockMethod" {
+		return nil, errors.New("unexpected method")
+	}
+	return api.Response{
+		StatusCode: http.StatusOK,
+		Body:       "mock-body",
+	}, nil
+}
+
+func TestCallReturnError(t *testing.T) {
+	var function *ReturnErrorFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	err = object.Call(
+		context.Background(),
+		&mocks.Logger{},
+		inputRPCHttpData,
+		nil,
+	)
+	assert.NoError(t, err)
+	returnValue, ok, err := object.ReturnValue()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	rpcHTTP := returnValue.Data.(*rpc.TypedData_Http).Http
+	assert.Equal(t, "200", rpcHTTP.StatusCode)
+	assert.Equal(t, "mock-body", rpcHTTP.Body.Data.(*rpc.TypedData_String_).String_)
+}
@@ -0,0 +1,54 @@
+package function
+
+import (
+	"reflect"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+type injectedField struct {
+	index []int
+	value reflect.Value
+}
+
+// Inject resolves services of function struct fields tagged with `azfunc:"inject"`.
+// Objects created by New after Inject have those fields set. Inject must be called before Init
+// for services to be available in Init.
+func (f *ObjectType) Inject(services *api.Services) error {
+	f.injected = nil
+	if f.kind != structFunction && f.kind != returnStructFunction {
+		return nil
+	}
+	var err error
+	for _, fi := range cachedTypeFields(f.objectType) {
+		if !fi.inject {
+			continue
+		}
+		v, resolveErr := services.Resolve(fi.typ)
+		if resolveErr != nil {
+			err = multierror.Append(err, errors.Wrapf(resolveErr, "could not inject field %s", fi.name))
+			continue
+		}
+		f.injected = append(f.injected, injectedField{index: fi.index, value: v})
+	}
+	return err
+}
+
+func (f *ObjectType) inject(instance reflect.Value) {
+	for _, fi := range f.injected {
+		v := instance.Elem()
+		last := len(fi.index) - 1
+		for _, i := range fi.index[:last] {
+			v = v.Field(i)
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+		}
+		v.Field(fi.index[last]).Set(fi.value)
+	}
+}
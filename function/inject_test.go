@@ -0,0 +1,97 @@
+package function_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	functionpkg "github.com/graphql-editor/azure-functions-golang-worker/function"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockConfig struct {
+	Greeting string
+}
+
+type mockGreeter struct {
+	name string
+}
+
+func (m *mockGreeter) String() string {
+	return m.name
+}
+
+type InjectedServices struct {
+	Config *mockConfig `azfunc:"inject"`
+}
+
+type InjectedFunction struct {
+	*InjectedServices
+	HTTPTrigger *api.Request
+	Original    []byte       `azfunc:"inject"`
+	Greeter     fmt.Stringer `azfunc:"inject"`
+	Initialized string
+}
+
+func (f *InjectedFunction) Init(ctx context.Context, logger api.Logger) error {
+	f.Initialized = f.Config.Greeting
+	return nil
+}
+
+func (f *InjectedFunction) Run(ctx context.Context, logger api.Logger) interface{} {
+	return f.Config.Greeting + " " + f.Greeter.String()
+}
+
+func TestInjectServices(t *testing.T) {
+	var function *InjectedFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		functionpkg.Bindings{
+			functionpkg.Binding{
+				Name: "original",
+				Type: "blob",
+			},
+		},
+		nil,
+	)
+	assert.NoError(t, err)
+	config := &mockConfig{Greeting: "hello"}
+	services := api.NewServices()
+	services.Register(config)
+	services.Register([]byte("service"))
+	services.Register(&mockGreeter{name: "world"})
+	assert.NoError(t, objectType.Inject(services))
+	assert.NoError(t, objectType.Init(context.Background(), &mocks.Logger{}))
+	object := objectType.New()
+	assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, inputRPCHttpData, nil, originalBindingData))
+	f := object.Interface().(*InjectedFunction)
+	assert.Same(t, config, f.Config)
+	assert.Equal(t, "hello", f.Initialized)
+	assert.Equal(t, []byte("service"), f.Original, "injected fields are not bindings")
+	rv, ok, err := object.ReturnValue()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", rv.GetHttp().GetBody().GetString_())
+}
+
+func TestInjectMissingService(t *testing.T) {
+	var function *InjectedFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	services := api.NewServices()
+	services.Register(&mockConfig{})
+	err = objectType.Inject(services)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "could not inject field Original: service []uint8 is not registered")
+		assert.Contains(t, err.Error(), "could not inject field Greeter: service fmt.Stringer is not registered")
+	}
+}
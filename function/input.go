@@ -1,11 +1,12 @@
 package function
 
 import (
+	"bytes"
 	"encoding/json"
 	"reflect"
 	"strconv"
-	"strings"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/converters"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/pkg/errors"
@@ -36,12 +37,9 @@ type fieldInputUnmarshaler struct {
 }
 
 func (f *fieldInputUnmarshaler) unmarshal(data *rpc.TypedData, v reflect.Value) error {
-	field, isPtr := getFieldValue(f.field, v)
-	if f.field.typ.Implements(unmarshalerInterface) || (isPtr && reflect.PtrTo(f.field.typ).Implements(unmarshalerInterface)) {
-		if isPtr {
-			field = field.Addr()
-		}
-		return field.Interface().(converters.Unmarshaler).Unmarshal(data)
+	field, _ := getFieldValue(f.field, v)
+	if reflect.PtrTo(f.field.typ).Implements(unmarshalerInterface) && field.CanAddr() {
+		return field.Addr().Interface().(converters.Unmarshaler).Unmarshal(data)
 	}
 	return f.set(data, field)
 }
@@ -290,20 +288,31 @@ func stringSliceSet(data *rpc.TypedData, v reflect.Value) error {
 	return nil
 }
 
-func newFieldInputUnmarshaler(binding Binding, t reflect.Type) unmarshaler {
-	fields := cachedTypeFields(t)
-	var field *field
-	for _, f := range fields {
-		if strings.EqualFold(binding.Name, f.name) {
-			field = &f
-			break
-		}
+var bytesReaderType = reflect.TypeOf((*bytes.Reader)(nil))
+
+func readerValueSet(data *rpc.TypedData, v reflect.Value) error {
+	var b []byte
+	switch td := data.Data.(type) {
+	case *rpc.TypedData_Bytes:
+		b = td.Bytes
+	case *rpc.TypedData_Stream:
+		b = td.Stream
+	case *rpc.TypedData_String_:
+		b = []byte(td.String_)
+	default:
+		return errors.Errorf("unsupported typedData for reader")
 	}
-	if field == nil {
+	v.Set(reflect.ValueOf(bytes.NewReader(b)))
+	return nil
+}
+
+func newFieldInputUnmarshaler(binding Binding, t reflect.Type) unmarshaler {
+	field, ok := fieldByName(t, binding.Name)
+	if !ok {
 		return nil
 	}
 	unmarshaler := fieldInputUnmarshaler{
-		field: *field,
+		field: field,
 	}
 	switch field.typ.Kind() {
 	case reflect.String:
@@ -334,6 +343,8 @@ func newFieldInputUnmarshaler(binding Binding, t reflect.Type) unmarshaler {
 	case reflect.Interface:
 		if field.typ.NumMethod() == 0 {
 			unmarshaler.set = interfaceValueSet
+		} else if bytesReaderType.AssignableTo(field.typ) {
+			unmarshaler.set = readerValueSet
 		}
 	case reflect.Map, reflect.Struct:
 		unmarshaler.set = mapStructSet
@@ -347,11 +358,42 @@ func newFieldInputUnmarshaler(binding Binding, t reflect.Type) unmarshaler {
 }
 
 func newInputUnmarshaler(binding Binding, t reflect.Type, kind kind) unmarshaler {
+	var u unmarshaler
 	switch kind {
 	case mapFunction:
-		return mapUnmarshaler(binding)
+		u = mapUnmarshaler(binding)
 	case structFunction, returnStructFunction:
-		return newFieldInputUnmarshaler(binding, t)
+		u = newFieldInputUnmarshaler(binding, t)
 	}
-	return nil
+	if u == nil || binding.DataType == "" {
+		return u
+	}
+	return func(data *rpc.TypedData, v reflect.Value) error {
+		return u(withDataType(data, binding.DataType), v)
+	}
+}
+
+// withDataType converts string, binary and stream data to data type of binding,
+// other data is returned as is
+func withDataType(data *rpc.TypedData, dataType string) *rpc.TypedData {
+	var b []byte
+	switch td := data.GetData().(type) {
+	case *rpc.TypedData_String_:
+		b = []byte(td.String_)
+	case *rpc.TypedData_Bytes:
+		b = td.Bytes
+	case *rpc.TypedData_Stream:
+		b = td.Stream
+	default:
+		return data
+	}
+	switch dataType {
+	case api.DataTypeString:
+		return &rpc.TypedData{Data: &rpc.TypedData_String_{String_: string(b)}}
+	case api.DataTypeBinary:
+		return &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: b}}
+	case api.DataTypeStream:
+		return &rpc.TypedData{Data: &rpc.TypedData_Stream{Stream: b}}
+	}
+	return data
 }
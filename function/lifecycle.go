@@ -0,0 +1,61 @@
+package function
+
+import (
+	"context"
+	"io"
+	"reflect"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/pkg/errors"
+)
+
+// copyPrototype copies initialized object to a new instance, so that each invocation
+// can set it's bindings without affecting other invocations
+func copyPrototype(instance, prototype reflect.Value) {
+	switch prototype.Elem().Kind() {
+	case reflect.Map:
+		iter := prototype.Elem().MapRange()
+		for iter.Next() {
+			instance.Elem().SetMapIndex(iter.Key(), iter.Value())
+		}
+	case reflect.Slice:
+		instance.Elem().Set(reflect.AppendSlice(instance.Elem(), prototype.Elem()))
+	default:
+		instance.Elem().Set(prototype.Elem())
+	}
+}
+
+// Init initializes function object if it implements api.Initializer. Objects created
+// by New after Init are copies of the initialized object.
+func (f *ObjectType) Init(ctx context.Context, logger api.Logger) (err error) {
+	obj := f.New()
+	initializer, ok := obj.instance.Interface().(api.Initializer)
+	_, closer := obj.instance.Interface().(io.Closer)
+	if !ok && !closer {
+		return nil
+	}
+	if ok {
+		defer func() {
+			if r := recover(); r != nil {
+				err = NewPanicError(r)
+			}
+		}()
+		if err := initializer.Init(ctx, logger); err != nil {
+			return errors.Wrap(err, "function initialization failed")
+		}
+	}
+	f.prototype = obj.instance
+	return nil
+}
+
+// Close closes initialized function object if it implements io.Closer
+func (f *ObjectType) Close() error {
+	if !f.prototype.IsValid() {
+		return nil
+	}
+	closer, ok := f.prototype.Interface().(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
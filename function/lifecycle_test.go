@@ -0,0 +1,112 @@
+package function_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	functionpkg "github.com/graphql-editor/azure-functions-golang-worker/function"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockClient struct {
+	inits  int
+	closed bool
+}
+
+type InitializedFunction struct {
+	HTTPTrigger *api.Request
+	Client      *mockClient
+}
+
+var initializedClient = &mockClient{}
+
+func (f *InitializedFunction) Init(ctx context.Context, logger api.Logger) error {
+	initializedClient.inits++
+	f.Client = initializedClient
+	return nil
+}
+
+func (f *InitializedFunction) Close() error {
+	f.Client.closed = true
+	return nil
+}
+
+func (f *InitializedFunction) Run(ctx context.Context, logger api.Logger) interface{} {
+	return f.HTTPTrigger.Method
+}
+
+type InitializedMapFunction map[string]interface{}
+
+func (f InitializedMapFunction) Init(ctx context.Context, logger api.Logger) error {
+	f["client"] = initializedClient
+	return nil
+}
+
+func (f InitializedMapFunction) Run(ctx context.Context, logger api.Logger) {
+	f["trigger"] = "set by invocation"
+}
+
+type FailingInitFunction struct {
+	HTTPTrigger *api.Request
+}
+
+func (f *FailingInitFunction) Init(ctx context.Context, logger api.Logger) error {
+	return errors.New("connection refused")
+}
+
+func (f *FailingInitFunction) Run(ctx context.Context, logger api.Logger) {}
+
+func TestInitCopiesInitializedObject(t *testing.T) {
+	var function *InitializedFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	initializedClient.inits = 0
+	assert.NoError(t, objectType.Init(context.Background(), &mocks.Logger{}))
+	for i := 0; i < 2; i++ {
+		object := objectType.New()
+		assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, inputRPCHttpData, nil))
+		assert.Same(t, initializedClient, object.Interface().(*InitializedFunction).Client)
+	}
+	assert.Equal(t, 1, initializedClient.inits)
+	assert.NoError(t, objectType.Close())
+	assert.True(t, initializedClient.closed)
+}
+
+func TestInitCopiesInitializedMap(t *testing.T) {
+	var function InitializedMapFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, objectType.Init(context.Background(), &mocks.Logger{}))
+	object := objectType.New()
+	assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, inputRPCHttpData, nil))
+	assert.Equal(t, "set by invocation", (*object.Interface().(*InitializedMapFunction))["trigger"])
+	other := objectType.New()
+	assert.Equal(t, InitializedMapFunction{"client": initializedClient}, *other.Interface().(*InitializedMapFunction))
+	assert.NoError(t, objectType.Close())
+}
+
+func TestInitError(t *testing.T) {
+	var function *FailingInitFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.HTTPTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	assert.EqualError(t, objectType.Init(context.Background(), &mocks.Logger{}), "function initialization failed: connection refused")
+}
@@ -2,8 +2,9 @@ package function
 
 import (
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"reflect"
-	"strings"
 
 	"github.com/graphql-editor/azure-functions-golang-worker/converters"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
@@ -182,12 +183,41 @@ func sliceOfBytesGet(v reflect.Value) (*rpc.TypedData, error) {
 
 var marshalerInterface = reflect.TypeOf((*converters.Marshaler)(nil)).Elem()
 
+var readerInterface = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+func readerValueGet(v reflect.Value) (*rpc.TypedData, error) {
+	if isNil(v) {
+		return nil, nil
+	}
+	if !v.Type().Implements(readerInterface) {
+		// reader implemented on pointer receiver, read through address of value
+		if !v.CanAddr() {
+			addressable := reflect.New(v.Type()).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		v = v.Addr()
+	}
+	b, err := ioutil.ReadAll(v.Interface().(io.Reader))
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{
+			Bytes: b,
+		},
+	}, nil
+}
+
 func marshalerForKind(t reflect.Type) marshaler {
 	if t.Implements(marshalerInterface) {
 		return func(v reflect.Value) (*rpc.TypedData, error) {
 			return v.Interface().(converters.Marshaler).Marshal()
 		}
 	}
+	if t.Kind() != reflect.Interface && (t.Implements(readerInterface) || reflect.PtrTo(t).Implements(readerInterface)) {
+		return readerValueGet
+	}
 	switch t.Kind() {
 	case reflect.Interface:
 		return interfaceValueGet
@@ -228,6 +258,9 @@ func interfaceValueGet(v reflect.Value) (*rpc.TypedData, error) {
 	}
 	if v.Kind() == reflect.Interface {
 		v = v.Elem()
+		if !v.IsValid() {
+			return nil, nil
+		}
 	}
 	if marshaler, ok := v.Interface().(converters.Marshaler); ok {
 		return marshaler.Marshal()
@@ -240,19 +273,12 @@ func interfaceValueGet(v reflect.Value) (*rpc.TypedData, error) {
 }
 
 func newFieldOutputMarshaler(binding Binding, t reflect.Type) marshaler {
-	fields := cachedTypeFields(t)
-	var field *field
-	for _, f := range fields {
-		if strings.EqualFold(binding.Name, f.name) {
-			field = &f
-			break
-		}
-	}
-	if field == nil {
+	field, ok := fieldByName(t, binding.Name)
+	if !ok {
 		return nil
 	}
 	marshaler := fieldOutputMarshaler{
-		field: *field,
+		field: field,
 		get:   marshalerForKind(field.typ),
 	}
 	if marshaler.get == nil {
@@ -0,0 +1,73 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PanicError is returned by Call when user function panics
+type PanicError struct {
+	// Value passed to panic
+	Value interface{}
+	stack errors.StackTrace
+}
+
+func (p *PanicError) Error() string {
+	var msg string
+	switch v := p.Value.(type) {
+	case error:
+		msg = v.Error()
+	case fmt.Stringer:
+		msg = v.String()
+	case string:
+		msg = v
+	default:
+		msg = fmt.Sprintf("%#v", v)
+	}
+	return "panic: " + msg
+}
+
+// StackTrace of panicking goroutine starting at the frame that called panic
+// up to the user function entry point
+func (p *PanicError) StackTrace() errors.StackTrace {
+	return p.stack
+}
+
+var workerFramePrefix = reflect.TypeOf(Object{}).PkgPath() + "."
+
+// NewPanicError converts value recovered from panic to PanicError with stack trace of panicking
+// goroutine. It must be called from deferred function that recovered the panic.
+func NewPanicError(v interface{}) *PanicError {
+	pcs := make([]uintptr, 256)
+	pcs = pcs[:runtime.Callers(0, pcs)]
+	var stack errors.StackTrace
+	inPanic := false
+	userFrames := -1
+	for _, pc := range pcs {
+		fn := runtime.FuncForPC(pc - 1)
+		if fn == nil {
+			continue
+		}
+		name := fn.Name()
+		if !inPanic {
+			inPanic = name == "runtime.gopanic"
+			continue
+		}
+		if userFrames == -1 && strings.HasPrefix(name, workerFramePrefix) {
+			userFrames = len(stack)
+		}
+		stack = append(stack, errors.Frame(pc))
+	}
+	// keep whole stack if panic did not originate in user code
+	if userFrames > 0 {
+		stack = stack[:userFrames]
+	}
+	return &PanicError{
+		Value: v,
+		stack: stack,
+	}
+}
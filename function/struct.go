@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
 )
 
 type field struct {
@@ -12,6 +14,7 @@ type field struct {
 	name      string
 	omitEmpty bool
 	asString  bool
+	inject    bool
 	index     []int
 }
 
@@ -63,6 +66,16 @@ func typeFields(t reflect.Type) []field {
 				index := make([]int, len(f.index)+1)
 				copy(index, f.index)
 				index[len(f.index)] = i
+				if tag == api.InjectTag {
+					// injected fields are not bindings and keep their type as is
+					fields = append(fields, field{
+						typ:    sf.Type,
+						name:   sf.Name,
+						inject: true,
+						index:  index,
+					})
+					continue
+				}
 				name := tag
 				ft := sf.Type
 				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
@@ -120,3 +133,13 @@ func cachedTypeFields(t reflect.Type) []field {
 	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
 	return f.([]field)
 }
+
+// fieldByName returns field of binding with name, names are compared using Unicode case-folding
+func fieldByName(t reflect.Type, name string) (field, bool) {
+	for _, f := range cachedTypeFields(t) {
+		if !f.inject && strings.EqualFold(name, f.name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
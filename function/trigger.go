@@ -0,0 +1,25 @@
+package function
+
+import (
+	"strings"
+	"sync"
+)
+
+const triggerSuffix = "trigger"
+
+var triggerExceptions sync.Map
+
+// RegisterTriggerType marks binding type as a trigger or not a trigger, overriding the convention
+// that binding types with a Trigger suffix are triggers. Binding types are compared case insensitively.
+func RegisterTriggerType(bindingType string, trigger bool) {
+	triggerExceptions.Store(strings.ToLower(bindingType), trigger)
+}
+
+// IsTrigger returns true if binding type is a trigger
+func IsTrigger(bindingType string) bool {
+	bindingType = strings.ToLower(bindingType)
+	if trigger, ok := triggerExceptions.Load(bindingType); ok {
+		return trigger.(bool)
+	}
+	return strings.HasSuffix(bindingType, triggerSuffix) && len(bindingType) > len(triggerSuffix)
+}
@@ -0,0 +1,354 @@
+package function_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	functionpkg "github.com/graphql-editor/azure-functions-golang-worker/function"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTrigger(t *testing.T) {
+	for _, tt := range []struct {
+		bindingType string
+		trigger     bool
+	}{
+		{"httpTrigger", true},
+		{"HttpTrigger", true},
+		{"queueTrigger", true},
+		{"orchestrationTrigger", true},
+		{"trigger", false},
+		{"queue", false},
+		{"http", false},
+	} {
+		assert.Equal(t, tt.trigger, functionpkg.IsTrigger(tt.bindingType), tt.bindingType)
+	}
+	functionpkg.RegisterTriggerType("customEvent", true)
+	functionpkg.RegisterTriggerType("notATrigger", false)
+	assert.True(t, functionpkg.IsTrigger("CustomEvent"))
+	assert.False(t, functionpkg.IsTrigger("notATrigger"))
+}
+
+var queueTriggerData = &rpc.TypedData{
+	Data: &rpc.TypedData_String_{
+		String_: "message",
+	},
+}
+
+type QueueTriggerByType struct {
+	QueueTrigger string
+}
+
+func (f *QueueTriggerByType) Run(ctx context.Context, logger api.Logger) interface{} {
+	return f.QueueTrigger
+}
+
+type QueueTriggerByName struct {
+	Message string `azfunc:"msg"`
+}
+
+func (f *QueueTriggerByName) Run(ctx context.Context, logger api.Logger) interface{} {
+	return f.Message
+}
+
+type QueueTriggerMap map[string]interface{}
+
+func (f QueueTriggerMap) Run(ctx context.Context, logger api.Logger) interface{} {
+	return f["queueTrigger"]
+}
+
+func TestTriggerObjectType(t *testing.T) {
+	for _, function := range []interface{}{
+		(*QueueTriggerByType)(nil),
+		(*QueueTriggerByName)(nil),
+		QueueTriggerMap(nil),
+	} {
+		objectType, err := functionpkg.NewTriggerObjectType(
+			reflect.TypeOf(function),
+			functionpkg.Binding{
+				Name: "msg",
+				Type: "queueTrigger",
+			},
+			nil,
+			nil,
+		)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		object := objectType.New()
+		assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, queueTriggerData, nil))
+		rv, ok, err := object.ReturnValue()
+		assert.True(t, ok)
+		assert.NoError(t, err)
+		assert.Equal(t, queueTriggerData, rv, "return value of non http trigger is not wrapped in http response")
+	}
+}
+
+type TimerTriggerFunction struct {
+	Timer api.TimerInfo `azfunc:"timerTrigger"`
+}
+
+func (f *TimerTriggerFunction) Run(ctx context.Context, logger api.Logger) {}
+
+func TestTimerTrigger(t *testing.T) {
+	var function *TimerTriggerFunction
+	objectType, err := functionpkg.NewObjectType(
+		reflect.TypeOf(function),
+		functionpkg.TimerTrigger,
+		nil,
+		nil,
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, &rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: `{"Schedule":{"AdjustForDST":true},"ScheduleStatus":null,"IsPastDue":true}`,
+		},
+	}, nil))
+	assert.Equal(t, api.TimerInfo{
+		Schedule:  api.Schedule{AdjustForDST: true},
+		IsPastDue: true,
+	}, object.Interface().(*TimerTriggerFunction).Timer)
+	_, ok, err := object.ReturnValue()
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+type QueueMessageFunction struct {
+	Message api.QueueMessage `azfunc:"msg"`
+	Out     api.QueueOutput
+}
+
+func (f *QueueMessageFunction) Run(ctx context.Context, logger api.Logger) {
+	f.Out.Add(f.Message.ID, f.Message.DequeueCount)
+}
+
+func TestQueueTriggerMetadata(t *testing.T) {
+	var function *QueueMessageFunction
+	objectType, err := functionpkg.NewTriggerObjectType(
+		reflect.TypeOf(function),
+		functionpkg.Binding{
+			Name: "msg",
+			Type: string(functionpkg.QueueTrigger),
+		},
+		nil,
+		functionpkg.Bindings{
+			functionpkg.Binding{
+				Name: "out",
+				Type: "queue",
+			},
+		},
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, queueTriggerData, map[string]*rpc.TypedData{
+		"Id":           &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "mockID"}},
+		"DequeueCount": &rpc.TypedData{Data: &rpc.TypedData_Int{Int: 3}},
+	}))
+	msg := object.Interface().(*QueueMessageFunction).Message
+	assert.Equal(t, "message", msg.Body)
+	assert.Equal(t, "mockID", msg.ID)
+	assert.Equal(t, int64(3), msg.DequeueCount)
+	out, ok, err := object.GetOutput("out")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["mockID", 3]`, out.GetJson())
+}
+
+type BlobFunction struct {
+	Blob     api.Blob  `azfunc:"blobTrigger"`
+	Input    io.Reader `azfunc:"input"`
+	Text     string    `azfunc:"text"`
+	Copy     io.Reader `azfunc:"copy"`
+	Uploaded interface{}
+}
+
+func (f *BlobFunction) Run(ctx context.Context, logger api.Logger) {
+	f.Copy = f.Blob.Reader()
+	f.Uploaded = f.Input
+}
+
+func TestBlobBindings(t *testing.T) {
+	var function *BlobFunction
+	objectType, err := functionpkg.NewTriggerObjectType(
+		reflect.TypeOf(function),
+		functionpkg.Binding{
+			Name:     "blob",
+			Type:     string(functionpkg.BlobTrigger),
+			DataType: api.DataTypeString,
+		},
+		functionpkg.Bindings{
+			functionpkg.Binding{
+				Name:     "input",
+				Type:     "blob",
+				DataType: api.DataTypeStream,
+			},
+			functionpkg.Binding{
+				Name:     "text",
+				Type:     "blob",
+				DataType: api.DataTypeString,
+			},
+		},
+		functionpkg.Bindings{
+			functionpkg.Binding{
+				Name: "copy",
+				Type: "blob",
+			},
+			functionpkg.Binding{
+				Name: "uploaded",
+				Type: "blob",
+			},
+		},
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, &rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{Bytes: []byte("content")},
+	}, map[string]*rpc.TypedData{
+		"BlobTrigger": &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "samples/blob.txt"}},
+	}, functionpkg.BindingData{
+		Name: "input",
+		Data: &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: []byte("input")}},
+	}, functionpkg.BindingData{
+		Name: "text",
+		Data: &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: []byte("text")}},
+	}))
+	f := object.Interface().(*BlobFunction)
+	assert.Equal(t, api.Blob{
+		Path:     "samples/blob.txt",
+		DataType: api.DataTypeString,
+		Content:  []byte("content"),
+	}, f.Blob)
+	assert.Equal(t, "text", f.Text)
+	out, ok, err := object.GetOutput("copy")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("content"), out.GetBytes())
+	out, ok, err = object.GetOutput("uploaded")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("input"), out.GetBytes())
+}
+
+type BlobReaderOutputFunction struct {
+	Blob    api.Blob        `azfunc:"blobTrigger"`
+	Buffer  *bytes.Buffer   `azfunc:"buffer"`
+	Reader  *strings.Reader `azfunc:"reader"`
+	Unset   *bytes.Buffer   `azfunc:"unset"`
+	Dynamic interface{}     `azfunc:"dynamic"`
+}
+
+func (f *BlobReaderOutputFunction) Run(ctx context.Context, logger api.Logger) {
+	f.Buffer = bytes.NewBuffer(f.Blob.Content)
+	f.Reader = strings.NewReader(f.Blob.String())
+	f.Dynamic = bytes.NewBufferString("dynamic")
+}
+
+func TestBlobPointerReaderOutputs(t *testing.T) {
+	var function *BlobReaderOutputFunction
+	objectType, err := functionpkg.NewTriggerObjectType(
+		reflect.TypeOf(function),
+		functionpkg.Binding{
+			Name: "blob",
+			Type: string(functionpkg.BlobTrigger),
+		},
+		nil,
+		functionpkg.Bindings{
+			functionpkg.Binding{Name: "buffer", Type: "blob"},
+			functionpkg.Binding{Name: "reader", Type: "blob"},
+			functionpkg.Binding{Name: "unset", Type: "blob"},
+			functionpkg.Binding{Name: "dynamic", Type: "blob"},
+		},
+	)
+	assert.NoError(t, err)
+	object := objectType.New()
+	assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, &rpc.TypedData{
+		Data: &rpc.TypedData_Bytes{Bytes: []byte("content")},
+	}, nil))
+	for binding, expected := range map[string][]byte{
+		"buffer":  []byte("content"),
+		"reader":  []byte("content"),
+		"dynamic": []byte("dynamic"),
+	} {
+		out, ok, err := object.GetOutput(binding)
+		assert.True(t, ok, binding)
+		assert.NoError(t, err, binding)
+		assert.Equal(t, expected, out.GetBytes(), binding)
+	}
+	out, ok, err := object.GetOutput("unset")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+type ServiceBusBatchFunction struct {
+	Messages []api.ServiceBusMessage `azfunc:"serviceBusTrigger"`
+	Out      api.ServiceBusOutput
+}
+
+func (f *ServiceBusBatchFunction) Run(ctx context.Context, logger api.Logger) {
+	for _, msg := range f.Messages {
+		f.Out.Add(msg.Body)
+	}
+}
+
+func TestServiceBusBatch(t *testing.T) {
+	var function *ServiceBusBatchFunction
+	objectType, err := functionpkg.NewTriggerObjectType(
+		reflect.TypeOf(function),
+		functionpkg.Binding{
+			Name: "messages",
+			Type: string(functionpkg.ServiceBusTrigger),
+		},
+		nil,
+		functionpkg.Bindings{
+			functionpkg.Binding{
+				Name: "out",
+				Type: "serviceBus",
+			},
+		},
+	)
+	assert.NoError(t, err)
+	metadata := map[string]*rpc.TypedData{
+		"MessageIdArray":      &rpc.TypedData{Data: &rpc.TypedData_CollectionString{CollectionString: &rpc.CollectionString{String_: []string{"first", "second"}}}},
+		"DeliveryCountArray":  &rpc.TypedData{Data: &rpc.TypedData_Json{Json: "[1, 2]"}},
+		"ContentTypeArray":    &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `["application/json", "text/plain"]`}},
+		"UserPropertiesArray": &rpc.TypedData{Data: &rpc.TypedData_Json{Json: `[{"n": 1}, {}]`}},
+		"SessionId":           &rpc.TypedData{Data: &rpc.TypedData_String_{String_: "shared"}},
+	}
+	for _, data := range []*rpc.TypedData{
+		&rpc.TypedData{Data: &rpc.TypedData_CollectionBytes{CollectionBytes: &rpc.CollectionBytes{Bytes: [][]byte{[]byte(`{"n":1}`), []byte("text")}}}},
+		&rpc.TypedData{Data: &rpc.TypedData_CollectionString{CollectionString: &rpc.CollectionString{String_: []string{`{"n":1}`, "text"}}}},
+		&rpc.TypedData{Data: &rpc.TypedData_Json{Json: `[{"n":1}, "text"]`}},
+	} {
+		object := objectType.New()
+		assert.NoError(t, object.Call(context.Background(), &mocks.Logger{}, data, metadata))
+		messages := object.Interface().(*ServiceBusBatchFunction).Messages
+		if !assert.Len(t, messages, 2) {
+			continue
+		}
+		assert.Equal(t, "first", messages[0].MessageID)
+		assert.Equal(t, int64(1), messages[0].DeliveryCount)
+		assert.Equal(t, map[string]interface{}{"n": float64(1)}, messages[0].UserProperties)
+		assert.Equal(t, "shared", messages[0].SessionID)
+		assert.Equal(t, "second", messages[1].MessageID)
+		assert.Equal(t, int64(2), messages[1].DeliveryCount)
+		assert.Equal(t, "text", messages[1].Body)
+		assert.Equal(t, "shared", messages[1].SessionID)
+		out, ok, err := object.GetOutput("out")
+		assert.True(t, ok)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[{"n": 1}, "text"]`, out.GetJson())
+	}
+	object := objectType.New()
+	assert.EqualError(t, object.Call(context.Background(), &mocks.Logger{}, &rpc.TypedData{
+		Data: &rpc.TypedData_String_{String_: "single"},
+	}, nil), "trigger data is not a batch")
+}
@@ -51,6 +51,26 @@ func (_m *Channel) SetEventStream(_a0 worker.Sender) {
 	_m.Called(_a0)
 }
 
+// SetHealth provides a mock function with given fields: _a0
+func (_m *Channel) SetHealth(_a0 *worker.Health) {
+	_m.Called(_a0)
+}
+
+// SetMetrics provides a mock function with given fields: _a0
+func (_m *Channel) SetMetrics(_a0 *worker.Metrics) {
+	_m.Called(_a0)
+}
+
+// SetMiddleware provides a mock function with given fields: middleware, functionMiddleware
+func (_m *Channel) SetMiddleware(middleware []worker.Middleware, functionMiddleware map[string][]worker.Middleware) {
+	_m.Called(middleware, functionMiddleware)
+}
+
+// SetHostInfo provides a mock function with given fields: _a0
+func (_m *Channel) SetHostInfo(_a0 *worker.HostInfo) {
+	_m.Called(_a0)
+}
+
 // SetLoader provides a mock function with given fields: _a0
 func (_m *Channel) SetLoader(_a0 worker.Loader) {
 	_m.Called(_a0)
@@ -86,16 +86,35 @@ func (g gobuild) build(logger api.Logger, path, out string) error {
 	return err
 }
 
+// RegisterServicesSymbol is a name of optional function exported by function plugin
+// that registers services injected into function. It must have a signature of
+//  func RegisterServices(services *api.Services) error
+const RegisterServicesSymbol = "RegisterServices"
+
 // Loader is a plugin loader that builds go function and returns reflection of function type
 type Loader struct {
-	binaries []string
-	lock     sync.Mutex
+	binaries  []string
+	providers map[string]func(*api.Services) error
+	lock      sync.Mutex
 }
 
 // GetFunctionType returns reflection of function type from go plugin
 func (l *Loader) GetFunctionType(fi worker.FunctionInfo, logger api.Logger) (reflect.Type, error) {
+	return l.load(fi, logger, false)
+}
+
+// ReloadFunctionType rebuilds function and returns reflection of function type from a new go plugin.
+// If a new plugin cannot be opened in running worker, error wrapping worker.ErrRestartRequired is returned.
+func (l *Loader) ReloadFunctionType(fi worker.FunctionInfo, logger api.Logger) (reflect.Type, error) {
+	return l.load(fi, logger, true)
+}
+
+func (l *Loader) load(fi worker.FunctionInfo, logger api.Logger, reload bool) (reflect.Type, error) {
 	var fpath string
 	if prebuilt := os.Getenv("AZURE_GOLANG_WORKER_PREBUILT_" + fi.Name); prebuilt != "" {
+		if reload {
+			return nil, errors.Wrap(worker.ErrRestartRequired, "prebuilt function cannot be rebuilt")
+		}
 		fpath = prebuilt
 	} else {
 		gobuild, err := newGoBuild()
@@ -120,15 +139,44 @@ func (l *Loader) GetFunctionType(fi worker.FunctionInfo, logger api.Logger) (ref
 	}
 	plug, err := plugin.Open(fpath)
 	if err != nil {
+		if reload {
+			// go runtime does not allow loading some plugin changes, for example
+			// a different version of a package already loaded by previous version of plugin
+			return nil, errors.Wrap(worker.ErrRestartRequired, err.Error())
+		}
 		return nil, errors.Wrap(err, "failed loading function plugin")
 	}
 	entrypoint, err := plug.Lookup(fi.EntryPoint)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("failed loooking up function entrypoint: %s", fi.EntryPoint))
 	}
+	var provider func(*api.Services) error
+	if sym, err := plug.Lookup(RegisterServicesSymbol); err == nil {
+		var ok bool
+		if provider, ok = sym.(func(*api.Services) error); !ok {
+			return nil, errors.Errorf("%s must be a func(*api.Services) error", RegisterServicesSymbol)
+		}
+	}
+	l.lock.Lock()
+	if l.providers == nil {
+		l.providers = make(map[string]func(*api.Services) error)
+	}
+	l.providers[fi.Name] = provider
+	l.lock.Unlock()
 	return reflect.TypeOf(entrypoint).Elem(), nil
 }
 
+// RegisterServices registers services of function using RegisterServices exported by function plugin
+func (l *Loader) RegisterServices(fi worker.FunctionInfo, services *api.Services) error {
+	l.lock.Lock()
+	provider := l.providers[fi.Name]
+	l.lock.Unlock()
+	if provider == nil {
+		return nil
+	}
+	return provider(services)
+}
+
 // Close cleans up after loader. Must be called before program exit to cleanup temporary binaries created by loader.
 func (l *Loader) Close() error {
 	l.lock.Lock()
@@ -139,6 +187,7 @@ func (l *Loader) Close() error {
 			collectErrors = multierror.Append(collectErrors, err)
 		}
 	}
+	l.binaries = nil
 	return collectErrors
 }
 
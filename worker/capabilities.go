@@ -1,5 +1,15 @@
 package worker
 
+import (
+	"encoding/json"
+	"mime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+)
+
 // Capability represents host capabilities
 type Capability string
 
@@ -8,15 +18,35 @@ func (c Capability) String() string { return string(c) }
 const (
 	// RPCHttpTriggerMetadataRemoved capability
 	RPCHttpTriggerMetadataRemoved Capability = "RpcHttpTriggerMetadataRemoved"
-	// RPCHttpBodyOnly capability
+	// RPCHttpBodyOnly capability, if enabled host sends only unparsed body of http request
+	// and worker restores raw body and decodes JSON body itself
 	RPCHttpBodyOnly Capability = "RpcHttpBodyOnly"
-	// RawHTTPBodyBytes capability
+	// RawHTTPBodyBytes capability, if enabled raw body of http request is bytes instead of string
 	RawHTTPBodyBytes Capability = "RawHttpBodyBytes"
+	// TypedDataCollection capability, if disabled collections are sent to host as json
+	TypedDataCollection Capability = "TypedDataCollection"
 )
 
+// DefaultCapabilities supported by worker
+var DefaultCapabilities = Capabilities{
+	RPCHttpTriggerMetadataRemoved: "true",
+	RPCHttpBodyOnly:               "true",
+	RawHTTPBodyBytes:              "true",
+	TypedDataCollection:           "true",
+}
+
 // Capabilities is a map of capabilites
 type Capabilities map[Capability]string
 
+// CapabilitiesFromRPC unmarshals map of capabilities from it's RPC representation
+func CapabilitiesFromRPC(m map[string]string) Capabilities {
+	c := make(Capabilities, len(m))
+	for k, v := range m {
+		c[Capability(k)] = v
+	}
+	return c
+}
+
 // ToRPC marshals map of capabilities to it's RPC representation
 func (c Capabilities) ToRPC() map[string]string {
 	m := make(map[string]string, len(c))
@@ -25,3 +55,242 @@ func (c Capabilities) ToRPC() map[string]string {
 	}
 	return m
 }
+
+// Negotiate returns capabilities enabled with host. Capability is disabled
+// if host declares it with a different value.
+func (c Capabilities) Negotiate(host Capabilities) Capabilities {
+	negotiated := make(Capabilities, len(c))
+	for k, v := range c {
+		if hv, ok := host[k]; !ok || hv == v {
+			negotiated[k] = v
+		}
+	}
+	return negotiated
+}
+
+func (c Capabilities) copy() Capabilities {
+	cp := make(Capabilities, len(c))
+	for k, v := range c {
+		cp[k] = v
+	}
+	return cp
+}
+
+// HostInfo holds information about host received in WorkerInitRequest and capabilities
+// negotiated with it
+type HostInfo struct {
+	// WorkerCapabilities are capabilities supported by worker. Defaults to DefaultCapabilities.
+	WorkerCapabilities Capabilities
+
+	lock          sync.RWMutex
+	initialized   bool
+	version       string
+	capabilities  Capabilities
+	negotiated    Capabilities
+	logCategories map[string]rpc.RpcLog_Level
+	hostLogLevels LogLevels
+	hostJSONRead  bool
+}
+
+func (h *HostInfo) workerCapabilities() Capabilities {
+	if h.WorkerCapabilities == nil {
+		return DefaultCapabilities
+	}
+	return h.WorkerCapabilities
+}
+
+// init records host information and returns negotiated capabilities
+func (h *HostInfo) init(msg *rpc.WorkerInitRequest) Capabilities {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.initialized = true
+	h.version = msg.GetHostVersion()
+	h.capabilities = CapabilitiesFromRPC(msg.GetCapabilities())
+	h.negotiated = h.workerCapabilities().Negotiate(h.capabilities)
+	h.logCategories = make(map[string]rpc.RpcLog_Level, len(msg.GetLogCategories()))
+	for k, v := range msg.GetLogCategories() {
+		h.logCategories[k] = v
+	}
+	return h.negotiated.copy()
+}
+
+// Version of host, empty before WorkerInitRequest
+func (h *HostInfo) Version() string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.version
+}
+
+// Capabilities declared by host
+func (h *HostInfo) Capabilities() Capabilities {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.capabilities.copy()
+}
+
+// Negotiated returns capabilities enabled with host. Before WorkerInitRequest all capabilities
+// supported by worker are considered enabled.
+func (h *HostInfo) Negotiated() Capabilities {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if !h.initialized {
+		return h.workerCapabilities().copy()
+	}
+	return h.negotiated.copy()
+}
+
+// Enabled returns true if capability was negotiated with host
+func (h *HostInfo) Enabled(c Capability) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	negotiated := h.negotiated
+	if !h.initialized {
+		negotiated = h.workerCapabilities()
+	}
+	_, ok := negotiated[c]
+	return ok
+}
+
+// LogCategories returns log levels of categories declared by host
+func (h *HostInfo) LogCategories() map[string]rpc.RpcLog_Level {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	categories := make(map[string]rpc.RpcLog_Level, len(h.logCategories))
+	for k, v := range h.logCategories {
+		categories[k] = v
+	}
+	return categories
+}
+
+// readHostJSON reads log levels from host.json in function app directory, host.json is read only once
+func (h *HostInfo) readHostJSON(appDirectory string) error {
+	h.lock.RLock()
+	read := h.hostJSONRead
+	h.lock.RUnlock()
+	if read {
+		return nil
+	}
+	levels, err := readHostLogLevels(appDirectory)
+	h.lock.Lock()
+	h.hostJSONRead = true
+	h.hostLogLevels = levels
+	h.lock.Unlock()
+	return err
+}
+
+// LogLevels returns log levels from host.json overridden by log categories declared by host
+func (h *HostInfo) LogLevels() LogLevels {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	levels := make(LogLevels, len(h.hostLogLevels)+len(h.logCategories))
+	for k, v := range h.hostLogLevels {
+		levels[k] = v
+	}
+	for k, v := range h.logCategories {
+		levels[k] = v
+	}
+	return levels
+}
+
+// LogLevel returns the lowest level of messages in category sent to host
+func (h *HostInfo) LogLevel(category string) rpc.RpcLog_Level {
+	return h.LogLevels().Level(category)
+}
+
+const workerModulePath = "github.com/graphql-editor/azure-functions-golang-worker"
+
+// workerVersion returns version of worker module from build info
+func workerVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	module := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == workerModulePath {
+			module = dep
+		}
+	}
+	if module.Path != workerModulePath {
+		return ""
+	}
+	if module.Replace != nil && module.Replace.Version != "" {
+		return module.Replace.Version
+	}
+	return module.Version
+}
+
+// withoutCollections replaces collections in typed data with json arrays for hosts that
+// do not support TypedDataCollection
+func withoutCollections(td *rpc.TypedData) (*rpc.TypedData, error) {
+	var v interface{}
+	switch data := td.GetData().(type) {
+	case *rpc.TypedData_CollectionBytes:
+		v = data.CollectionBytes.GetBytes()
+	case *rpc.TypedData_CollectionString:
+		v = data.CollectionString.GetString_()
+	case *rpc.TypedData_CollectionSint64:
+		v = data.CollectionSint64.GetSint64()
+	case *rpc.TypedData_CollectionDouble:
+		v = data.CollectionDouble.GetDouble()
+	case *rpc.TypedData_Http:
+		if body := data.Http.GetBody(); body != nil {
+			body, err := withoutCollections(body)
+			if err != nil {
+				return nil, err
+			}
+			data.Http.Body = body
+		}
+		return td, nil
+	default:
+		return td, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.TypedData{
+		Data: &rpc.TypedData_Json{
+			Json: string(b),
+		},
+	}, nil
+}
+
+// isJSONContentType returns true for application/json and +json media types
+func isJSONContentType(headers map[string]string) bool {
+	for k, v := range headers {
+		if !strings.EqualFold(k, "Content-Type") {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(v)
+		return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+	}
+	return false
+}
+
+// withHTTPBody restores raw body and decodes JSON body of http request sent by host
+// with RpcHttpBodyOnly capability. Raw body is bytes if rawBytes is true, otherwise it's a string.
+func withHTTPBody(td *rpc.TypedData, rawBytes bool) *rpc.TypedData {
+	req := td.GetHttp()
+	if req == nil || req.GetBody() == nil || req.GetRawBody() != nil {
+		return td
+	}
+	var b []byte
+	switch data := req.Body.GetData().(type) {
+	case *rpc.TypedData_String_:
+		b = []byte(data.String_)
+	case *rpc.TypedData_Bytes:
+		b = data.Bytes
+	default:
+		return td
+	}
+	restored := *req
+	restored.RawBody = &rpc.TypedData{Data: &rpc.TypedData_String_{String_: string(b)}}
+	if rawBytes {
+		restored.RawBody = &rpc.TypedData{Data: &rpc.TypedData_Bytes{Bytes: b}}
+	}
+	if isJSONContentType(req.GetHeaders()) && json.Valid(b) {
+		restored.Body = &rpc.TypedData{Data: &rpc.TypedData_Json{Json: string(b)}}
+	}
+	return &rpc.TypedData{Data: &rpc.TypedData_Http{Http: &restored}}
+}
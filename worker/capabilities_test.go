@@ -1,10 +1,16 @@
 package worker_test
 
 import (
+	"context"
+	"reflect"
 	"testing"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/graphql-editor/azure-functions-golang-worker/worker"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestCapabilitiesRPCMarshal(t *testing.T) {
@@ -22,3 +28,194 @@ func TestCapabilitiesRPCMarshal(t *testing.T) {
 		}.ToRPC(),
 	)
 }
+
+func TestCapabilitiesNegotiate(t *testing.T) {
+	assert.Equal(
+		t,
+		worker.Capabilities{
+			worker.RPCHttpBodyOnly:     "true",
+			worker.TypedDataCollection: "true",
+		},
+		worker.Capabilities{
+			worker.RPCHttpBodyOnly:     "true",
+			worker.RawHTTPBodyBytes:    "true",
+			worker.TypedDataCollection: "true",
+		}.Negotiate(worker.Capabilities{
+			worker.RawHTTPBodyBytes:    "false",
+			worker.TypedDataCollection: "true",
+		}),
+	)
+}
+
+type MockCollectionFuncForChannel map[string]interface{}
+
+func (m MockCollectionFuncForChannel) Run(ctx context.Context, logger api.Logger) {
+	m["out"] = []string{"a", "b"}
+}
+
+func TestHostInfoDisablesCapabilities(t *testing.T) {
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockCollectionFuncForChannel)(nil)).Elem(), nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	hostInfo := &worker.HostInfo{}
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetHostInfo(hostInfo)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+	})
+	assert.True(t, hostInfo.Enabled(worker.TypedDataCollection))
+	ch.InitRequest("mockRequestID", &rpc.WorkerInitRequest{
+		HostVersion: "3.0.0",
+		Capabilities: map[string]string{
+			"TypedDataCollection": "false",
+			"RawHttpBodyBytes":    "false",
+		},
+		LogCategories: map[string]rpc.RpcLog_Level{
+			"Worker": rpc.RpcLog_Warning,
+		},
+	})
+	assert.Equal(t, "3.0.0", hostInfo.Version())
+	assert.Equal(t, map[string]rpc.RpcLog_Level{"Worker": rpc.RpcLog_Warning}, hostInfo.LogCategories())
+	assert.False(t, hostInfo.Enabled(worker.TypedDataCollection))
+	assert.False(t, hostInfo.Enabled(worker.RawHTTPBodyBytes))
+	assert.True(t, hostInfo.Enabled(worker.RPCHttpBodyOnly))
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		resp := v.(*rpc.StreamingMessage).GetWorkerInitResponse()
+		return resp != nil && assert.Equal(t, map[string]string{
+			"RpcHttpTriggerMetadataRemoved": "true",
+			"RpcHttpBodyOnly":               "true",
+		}, resp.Capabilities)
+	}))
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata: &rpc.RpcFunctionMetadata{
+			Name: "func",
+			Bindings: map[string]*rpc.BindingInfo{
+				"trigger": &rpc.BindingInfo{
+					Type:      "httpTrigger",
+					Direction: rpc.BindingInfo_in,
+				},
+				"out": &rpc.BindingInfo{
+					Type:      "queue",
+					Direction: rpc.BindingInfo_out,
+				},
+			},
+		},
+	})
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		resp := v.(*rpc.StreamingMessage).GetInvocationResponse()
+		return resp != nil && assert.Equal(t, []*rpc.ParameterBinding{
+			&rpc.ParameterBinding{
+				Name: "out",
+				Data: &rpc.TypedData{
+					Data: &rpc.TypedData_Json{
+						Json: `["a","b"]`,
+					},
+				},
+			},
+		}, resp.OutputData)
+	}))
+}
+
+var httpBodyRequests = make(chan api.Request, 1)
+
+type MockHTTPBodyFuncForChannel struct {
+	Trigger *api.Request `azfunc:"trigger"`
+}
+
+func (m *MockHTTPBodyFuncForChannel) Run(ctx context.Context, logger api.Logger) {
+	httpBodyRequests <- *m.Trigger
+}
+
+func TestHostInfoHTTPBodyCapabilities(t *testing.T) {
+	data := []struct {
+		capabilities    map[string]string
+		headers         map[string]string
+		expectedBody    interface{}
+		expectedRawBody interface{}
+	}{
+		{
+			capabilities:    map[string]string{"RpcHttpBodyOnly": "true", "RawHttpBodyBytes": "true"},
+			headers:         map[string]string{"content-type": "application/json; charset=utf-8"},
+			expectedBody:    map[string]interface{}{"a": float64(1)},
+			expectedRawBody: []byte(`{"a":1}`),
+		},
+		{
+			capabilities:    map[string]string{"RpcHttpBodyOnly": "true", "RawHttpBodyBytes": "false"},
+			headers:         map[string]string{"Content-Type": "application/json"},
+			expectedBody:    map[string]interface{}{"a": float64(1)},
+			expectedRawBody: `{"a":1}`,
+		},
+		{
+			capabilities:    map[string]string{"RpcHttpBodyOnly": "true", "RawHttpBodyBytes": "true"},
+			headers:         map[string]string{"Content-Type": "text/plain"},
+			expectedBody:    []byte(`{"a":1}`),
+			expectedRawBody: []byte(`{"a":1}`),
+		},
+		{
+			// host sends body and raw body as is
+			capabilities:    map[string]string{"RpcHttpBodyOnly": "false", "RawHttpBodyBytes": "true"},
+			headers:         map[string]string{"Content-Type": "application/json"},
+			expectedBody:    []byte(`{"a":1}`),
+			expectedRawBody: []byte(`{"a":1}`),
+		},
+	}
+	for _, tt := range data {
+		var mockLoader mocks.TypeLoader
+		mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockHTTPBodyFuncForChannel)(nil)), nil)
+		var mockSender mocks.Sender
+		mockSender.On("Send", mock.Anything)
+		ch := worker.NewChannel()
+		ch.SetEventStream(&mockSender)
+		ch.SetHostInfo(&worker.HostInfo{})
+		ch.SetLoader(worker.Loader{
+			TypeLoader:      &mockLoader,
+			LoadedFunctions: map[string]worker.Function{},
+		})
+		ch.InitRequest("mockRequestID", &rpc.WorkerInitRequest{
+			Capabilities: tt.capabilities,
+		})
+		ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+			FunctionId: "mockFunctionID",
+			Metadata: &rpc.RpcFunctionMetadata{
+				Name: "func",
+				Bindings: map[string]*rpc.BindingInfo{
+					"trigger": &rpc.BindingInfo{
+						Type:      "httpTrigger",
+						Direction: rpc.BindingInfo_in,
+					},
+				},
+			},
+		})
+		ch.InvocationRequest("mockRequestID", &rpc.InvocationRequest{
+			FunctionId:   "mockFunctionID",
+			InvocationId: "mockInvocationID",
+			InputData: []*rpc.ParameterBinding{
+				&rpc.ParameterBinding{
+					Name: "trigger",
+					Data: &rpc.TypedData{
+						Data: &rpc.TypedData_Http{
+							Http: &rpc.RpcHttp{
+								Headers: tt.headers,
+								Body: &rpc.TypedData{
+									Data: &rpc.TypedData_Bytes{Bytes: []byte(`{"a":1}`)},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		select {
+		case req := <-httpBodyRequests:
+			assert.Equal(t, tt.expectedBody, req.Body)
+			assert.Equal(t, tt.expectedRawBody, req.RawBody)
+		default:
+			t.Fatal("function was not called")
+		}
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/function"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/pkg/errors"
@@ -14,52 +17,187 @@ import (
 type channel struct {
 	stream Sender
 	loader Loader
-	logger api.Logger
+	// loaderLock guards loaded functions as invocations run concurrently with function loads
+	loaderLock  sync.RWMutex
+	invocations invocations
+	health      *Health
+	metrics     *Metrics
+	hostInfo    *HostInfo
+	// middleware wraps invocations of all functions, functionMiddleware wraps invocations of a function
+	middleware         []Middleware
+	functionMiddleware map[string][]Middleware
+	// versions count invocations running on loaded functions, so that version replaced on reload
+	// is closed once it's invocations finish
+	versionsLock sync.Mutex
+	versions     map[string]*functionVersion
+	// reloadLocks serialize loads and reloads of a single function
+	reloadLocks sync.Map
+	// reloads tracks reloads running in background
+	reloads sync.WaitGroup
 }
 
 func (c *channel) StartStream(requestID string, msg *rpc.StartStream) {
 	// not yet implemented
 }
 
+// InitRequest records host information and responds with capabilities negotiated with host
 func (c *channel) InitRequest(requestID string, msg *rpc.WorkerInitRequest) {
+	capabilities := c.hostInfo.init(msg)
 	c.stream.Send(&rpc.StreamingMessage{
 		RequestId: requestID,
 		Content: &rpc.StreamingMessage_WorkerInitResponse{
 			WorkerInitResponse: &rpc.WorkerInitResponse{
-				Result: c.getStatus(nil),
-				Capabilities: Capabilities{
-					RPCHttpTriggerMetadataRemoved: "true",
-					RPCHttpBodyOnly:               "true",
-					RawHTTPBodyBytes:              "true",
-				}.ToRPC(),
+				WorkerVersion: workerVersion(),
+				Result:        c.getStatus(nil),
+				Capabilities:  capabilities.ToRPC(),
 			},
 		},
 	})
 }
 
+// Heartbeat responds with heartbeat and reports stuck invocations
 func (c *channel) Heartbeat(requestID string, msg *rpc.WorkerHeartbeat) {
-	// not yet implemented
+	c.reportStuckInvocations()
+	c.stream.Send(&rpc.StreamingMessage{
+		RequestId: requestID,
+		Content: &rpc.StreamingMessage_WorkerHeartbeat{
+			WorkerHeartbeat: &rpc.WorkerHeartbeat{},
+		},
+	})
 }
 
+// Terminate cancels all invocations still running after grace period. Worker is
+// responsible for draining invocations and closing the stream.
 func (c *channel) Terminate(requestID string, msg *rpc.WorkerTerminate) {
-	// not yet implemented
+	period := gracePeriod(msg.GetGracePeriod())
+	c.systemLogger().Info(fmt.Sprintf("Worker terminating, waiting %v for running invocations", period))
+	c.invocations.cancelAll(period)
 }
 
 func (c *channel) StatusRequest(requestID string, msg *rpc.WorkerStatusRequest) {
-	// not yet implemented
+	c.reportStuckInvocations()
+	c.stream.Send(&rpc.StreamingMessage{
+		RequestId: requestID,
+		Content: &rpc.StreamingMessage_WorkerStatusResponse{
+			WorkerStatusResponse: &rpc.WorkerStatusResponse{},
+		},
+	})
+}
+
+func (c *channel) reportStuckInvocations() {
+	status := c.health.Status()
+	for _, inv := range status.StuckInvocations {
+		c.systemLogger().Warn(fmt.Sprintf(
+			"Invocation %s of function %s has been running for %v",
+			inv.InvocationID,
+			inv.FunctionID,
+			inv.Running,
+		))
+	}
+}
+
+func isInDirectory(path, dir string) bool {
+	if path == "" || dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
+// FileChangeEventRequest reloads functions affected by the change in background
 func (c *channel) FileChangeEventRequest(requestID string, msg *rpc.FileChangeEventRequest) {
-	// not yet implemented
+	changed := make(map[string]FunctionInfo)
+	c.loaderLock.RLock()
+	for functionID, f := range c.loader.LoadedFunctions {
+		if (msg.GetName() != "" && msg.GetName() == f.Info.Name) || isInDirectory(msg.GetFullPath(), f.Info.Directory) {
+			changed[functionID] = f.Info
+		}
+	}
+	c.loaderLock.RUnlock()
+	if len(changed) == 0 {
+		c.systemLogger().Debug(fmt.Sprintf("File %s does not belong to any loaded function, ignoring change", msg.GetFullPath()))
+		return
+	}
+	for functionID, info := range changed {
+		c.reloads.Add(1)
+		go func(functionID string, info FunctionInfo) {
+			defer c.reloads.Done()
+			c.reload(requestID, functionID, info)
+		}(functionID, info)
+	}
+}
+
+func lockFor(locks *sync.Map, functionID string) *sync.RWMutex {
+	lock, _ := locks.LoadOrStore(functionID, &sync.RWMutex{})
+	return lock.(*sync.RWMutex)
+}
+
+func (c *channel) reload(requestID, functionID string, info FunctionInfo) {
+	reloadLock := lockFor(&c.reloadLocks, functionID)
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	c.systemLogger().Info(fmt.Sprintf("Reloading function %s", info.Name))
+	c.loaderLock.RLock()
+	loader := c.loader
+	c.loaderLock.RUnlock()
+	f, err := loader.Reload(info, c.systemLogger())
+	if errors.Cause(err) == ErrRestartRequired {
+		c.sendWorkerAction(requestID, rpc.WorkerActionResponse_Restart, fmt.Sprintf("function %s cannot be reloaded: %v", info.Name, err))
+		return
+	}
+	if err != nil {
+		c.systemLogger().Error(fmt.Sprintf("Worker was unable to reload function %s, previous version is kept: %v", info.Name, err))
+		return
+	}
+	c.replaceFunction(functionID, f)
+	c.sendWorkerAction(requestID, rpc.WorkerActionResponse_Reload, fmt.Sprintf("function %s reloaded", info.Name))
+}
+
+// CloseFunctions waits for reloads running in background and closes loaded functions
+func (c *channel) CloseFunctions() error {
+	c.reloads.Wait()
+	c.loaderLock.Lock()
+	defer c.loaderLock.Unlock()
+	return c.loader.CloseFunctions()
+}
+
+func (c *channel) sendWorkerAction(requestID string, action rpc.WorkerActionResponse_Action, reason string) {
+	c.stream.Send(&rpc.StreamingMessage{
+		RequestId: requestID,
+		Content: &rpc.StreamingMessage_WorkerActionResponse{
+			WorkerActionResponse: &rpc.WorkerActionResponse{
+				Action: action,
+				Reason: reason,
+			},
+		},
+	})
 }
 
 func (c *channel) FunctionLoadRequest(requestID string, msg *rpc.FunctionLoadRequest) {
 	functionID := msg.GetFunctionId()
 	metadata := msg.GetMetadata()
 	if functionID != "" && metadata != nil {
-		err := c.loader.Load(functionID, metadata, c.logger)
+		if metadata.GetDirectory() != "" {
+			if err := c.hostInfo.readHostJSON(filepath.Dir(metadata.GetDirectory())); err != nil {
+				c.systemLogger().Warn(fmt.Sprintf("Worker was unable to read log levels from host.json: %v", err))
+			}
+		}
+		// function is built and initialized without holding loaderLock, so that invocations
+		// of loaded functions are not blocked by a slow load
+		reloadLock := lockFor(&c.reloadLocks, functionID)
+		reloadLock.Lock()
+		c.loaderLock.RLock()
+		loader := c.loader
+		c.loaderLock.RUnlock()
+		start := time.Now()
+		f, err := loader.LoadFunction(metadata, c.systemLogger())
+		if err == nil {
+			c.replaceFunction(functionID, f)
+			c.metrics.functionLoaded(metadata.GetName(), time.Since(start))
+		}
+		reloadLock.Unlock()
 		if err != nil {
-			c.logger.Error(
+			c.systemLogger().Error(
 				fmt.Sprintf(
 					"Worker was unable to load function %s: %v",
 					metadata.GetName(),
@@ -79,90 +217,256 @@ func (c *channel) FunctionLoadRequest(requestID string, msg *rpc.FunctionLoadReq
 	}
 }
 
-func (c *channel) InvocationRequest(requestID string, msg *rpc.InvocationRequest) {
-	functionID := msg.GetFunctionId()
-	info, err := c.loader.Info(functionID)
-	outputData := make([]*rpc.ParameterBinding, 0, len(info.OutputBindings))
-	var returnValue *rpc.TypedData
-	inputData := make([]function.BindingData, 0, len(msg.InputData))
-	var triggerData *rpc.TypedData
-	var obj function.Object
+// functionVersion is a version of loaded function with number of invocations running on it
+type functionVersion struct {
+	function Function
+	running  int
+	retired  bool
+}
+
+// acquireFunction returns loaded function and it's version, version must be released
+// with releaseFunction once invocation finishes
+func (c *channel) acquireFunction(functionID string) (info FunctionInfo, objType function.ObjectType, version *functionVersion, err error) {
+	c.loaderLock.RLock()
+	defer c.loaderLock.RUnlock()
+	info, err = c.loader.Info(functionID)
 	if err == nil {
-		for _, binding := range msg.InputData {
-			if info.TriggerBindingName == binding.GetName() {
-				triggerData = binding.Data
-			} else {
-				inputData = append(inputData, function.BindingData{
-					Name: binding.Name,
-					Data: binding.Data,
-				})
-			}
+		objType, err = c.loader.Func(functionID)
+	}
+	if err != nil {
+		return
+	}
+	c.versionsLock.Lock()
+	defer c.versionsLock.Unlock()
+	version, ok := c.versions[functionID]
+	if !ok {
+		if c.versions == nil {
+			c.versions = make(map[string]*functionVersion)
 		}
+		version = &functionVersion{function: c.loader.LoadedFunctions[functionID]}
+		c.versions[functionID] = version
 	}
-	if err == nil {
-		var objType function.ObjectType
-		objType, err = c.loader.Func(functionID)
-		if err == nil {
-			obj = objType.New()
-			err = obj.Call(
-				context.Background(),
-				Logger{
-					InvocationID: msg.GetInvocationId(),
-					EventID:      requestID,
-					Stream:       c.stream,
-					Cat:          rpc.RpcLog_User,
-				},
-				triggerData,
-				msg.TriggerMetadata,
-				inputData...,
-			)
+	version.running++
+	return
+}
+
+// releaseFunction closes retired version of function after it's last invocation
+func (c *channel) releaseFunction(version *functionVersion) {
+	if version == nil {
+		return
+	}
+	c.versionsLock.Lock()
+	version.running--
+	idle := version.retired && version.running == 0
+	c.versionsLock.Unlock()
+	if idle {
+		closeFunction(version.function, c.systemLogger())
+	}
+}
+
+// replaceFunction stores new version of function. New invocations use it right away, previous
+// version is closed once invocations still running on it finish.
+func (c *channel) replaceFunction(functionID string, f Function) {
+	c.loaderLock.Lock()
+	previous, ok := c.loader.LoadedFunctions[functionID]
+	c.loader.LoadedFunctions[functionID] = f
+	idle := c.retireVersion(functionID)
+	c.loaderLock.Unlock()
+	if ok && idle {
+		closeFunction(previous, c.systemLogger())
+	}
+}
+
+// retireVersion detaches current version of function from new invocations. Returns false if
+// invocations are still running on it, in which case the last one closes it. Must be called
+// with loaderLock held for writing.
+func (c *channel) retireVersion(functionID string) bool {
+	c.versionsLock.Lock()
+	defer c.versionsLock.Unlock()
+	version, ok := c.versions[functionID]
+	if !ok {
+		return true
+	}
+	delete(c.versions, functionID)
+	version.retired = true
+	return version.running == 0
+}
+
+// InvocationQueued registers invocation waiting for a free slot in dispatcher, so that
+// host can cancel it before it starts
+func (c *channel) InvocationQueued(requestID string, msg *rpc.InvocationRequest) {
+	c.invocations.queue(msg.GetInvocationId())
+}
+
+func (c *channel) InvocationRequest(requestID string, msg *rpc.InvocationRequest) {
+	functionID := msg.GetFunctionId()
+	inv := c.invocations.start(msg.GetInvocationId())
+	defer c.invocations.finish(msg.GetInvocationId())
+	c.health.invocationStarted(msg.GetInvocationId(), functionID)
+	info, objType, version, err := c.acquireFunction(functionID)
+	defer c.releaseFunction(version)
+	functionName := info.Name
+	if functionName == "" {
+		functionName = functionID
+	}
+	requestSize := 0
+	for _, binding := range msg.InputData {
+		requestSize += payloadSize(binding.GetData())
+	}
+	c.metrics.invocationStarted(functionName, requestSize)
+	start := time.Now()
+	response := &rpc.InvocationResponse{
+		InvocationId: msg.GetInvocationId(),
+		OutputData:   make([]*rpc.ParameterBinding, 0, len(info.OutputBindings)),
+	}
+	// invocation cancelled while queued is answered without calling function
+	if err == nil && !inv.isCancelled() {
+		ctx := withTraceContext(inv.ctx, msg.GetTraceContext())
+		if info.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, info.Timeout)
+			defer cancel()
+		}
+		ctx = withInvocationInfo(ctx, msg.GetInvocationId(), functionID, info)
+		invocation := &Invocation{
+			Info:    info,
+			Request: msg,
+			Object:  objType.New(),
+			Logger: Logger{
+				InvocationID: msg.GetInvocationId(),
+				EventID:      requestID,
+				Stream:       c.stream,
+				FunctionName: info.Name,
+				Cat:          rpc.RpcLog_User,
+				MinLevel:     c.hostInfo.LogLevel(FunctionLogCategory + "." + info.Name),
+			},
+			Response: response,
+		}
+		handler := chain(c.callFunction, c.middleware, c.functionMiddleware[info.Name])
+		err = handleInvocation(ctx, handler, invocation)
+		if invocation.Response != nil {
+			response = invocation.Response
+		}
+		if errors.Cause(err) == function.ErrTimeout {
+			err = errors.Errorf("function %s timed out after %v", info.Name, info.Timeout)
+			Logger{
+				InvocationID: msg.GetInvocationId(),
+				EventID:      requestID,
+				Stream:       c.stream,
+				FunctionName: info.Name,
+				Cat:          rpc.RpcLog_System,
+				MinLevel:     c.hostInfo.LogLevel(WorkerLogCategory),
+			}.Error(fmt.Sprintf("%v, goroutine dump:\n%s", err, goroutineDump()))
 		}
 	}
-	if err == nil {
-		var ok bool
-		returnValue, ok, err = obj.ReturnValue()
-		if !ok && err == nil {
-			returnValue = nil
-		}
-		for name := range info.OutputBindings {
-			var outputValue *rpc.TypedData
-			outputValue, ok, err = obj.GetOutput(name)
-			if err != nil {
-				break
-			}
-			if ok {
-				outputData = append(outputData, &rpc.ParameterBinding{
-					Name: name,
-					Data: outputValue,
-				})
-			}
+	result := c.getStatus(err)
+	if result.Exception != nil {
+		result.Exception.Source = info.Name
+	}
+	_, panicked := err.(*function.PanicError)
+	if panicked && c.health.invocationPanicked() {
+		c.systemLogger().Fatal(fmt.Sprintf("Function %s panicked, panic limit reached, restarting worker", info.Name))
+	}
+	if inv.isCancelled() {
+		response.OutputData, response.ReturnValue = nil, nil
+		result = &rpc.StatusResult{
+			Status: rpc.StatusResult_Cancelled,
+			Result: "invocation cancelled by host",
 		}
 	}
+	response.Result = result
+	c.health.invocationFinished(msg.GetInvocationId(), result.Status == rpc.StatusResult_Success)
+	responseSize := payloadSize(response.ReturnValue)
+	for _, binding := range response.OutputData {
+		responseSize += payloadSize(binding.GetData())
+	}
+	c.metrics.invocationFinished(functionName, invocationStatus(result.Status), time.Since(start), responseSize, panicked)
 	c.stream.Send(&rpc.StreamingMessage{
 		RequestId: requestID,
 		Content: &rpc.StreamingMessage_InvocationResponse{
-			InvocationResponse: &rpc.InvocationResponse{
-				InvocationId: msg.GetInvocationId(),
-				OutputData:   outputData,
-				ReturnValue:  returnValue,
-				Result:       c.getStatus(err),
-			},
+			InvocationResponse: response,
 		},
 	})
 }
 
+// callFunction is the innermost invocation handler, it calls function and sets output data
+// and return value of response
+func (c *channel) callFunction(ctx context.Context, inv *Invocation) error {
+	inputData := make([]function.BindingData, 0, len(inv.Request.InputData))
+	var triggerData *rpc.TypedData
+	bodyOnly, rawBytes := c.hostInfo.Enabled(RPCHttpBodyOnly), c.hostInfo.Enabled(RawHTTPBodyBytes)
+	for _, binding := range inv.Request.InputData {
+		data := binding.Data
+		if bodyOnly {
+			data = withHTTPBody(data, rawBytes)
+		}
+		if inv.Info.TriggerBindingName == binding.GetName() {
+			triggerData = data
+		} else {
+			inputData = append(inputData, function.BindingData{
+				Name: binding.Name,
+				Data: data,
+			})
+		}
+	}
+	err := inv.Object.Call(
+		ctx,
+		inv.Logger,
+		triggerData,
+		inv.Request.TriggerMetadata,
+		inputData...,
+	)
+	if err != nil {
+		return err
+	}
+	collections := c.hostInfo.Enabled(TypedDataCollection)
+	returnValue, ok, err := inv.Object.ReturnValue()
+	if !ok && err == nil {
+		returnValue = nil
+	}
+	if returnValue != nil && err == nil && !collections {
+		returnValue, err = withoutCollections(returnValue)
+	}
+	inv.Response.ReturnValue = returnValue
+	for name := range inv.Info.OutputBindings {
+		if err != nil {
+			break
+		}
+		var outputValue *rpc.TypedData
+		outputValue, ok, err = inv.Object.GetOutput(name)
+		if ok && err == nil && !collections {
+			outputValue, err = withoutCollections(outputValue)
+		}
+		if err != nil {
+			break
+		}
+		if ok {
+			inv.Response.OutputData = append(inv.Response.OutputData, &rpc.ParameterBinding{
+				Name: name,
+				Data: outputValue,
+			})
+		}
+	}
+	return err
+}
+
+// InvocationCancel cancels context of running invocation once grace period passes.
+// Invocations waiting in dispatcher queue are cancelled immediately and answered with
+// cancelled status without calling function. Finished invocations are not affected.
 func (c *channel) InvocationCancel(requestID string, msg *rpc.InvocationCancel) {
-	// not yet implemented
+	if !c.invocations.cancel(msg.GetInvocationId(), gracePeriod(msg.GetGracePeriod())) {
+		c.systemLogger().Warn(fmt.Sprintf("Invocation %s is not running and cannot be cancelled", msg.GetInvocationId()))
+	}
 }
 
 func (c *channel) FunctionEnvironmentReloadRequest(requestID string, msg *rpc.FunctionEnvironmentReloadRequest) {
-	c.logger.Info(fmt.Sprintf("Reloading environment variables. Found %d variables to reload", len(msg.EnvironmentVariables)))
+	c.systemLogger().Info(fmt.Sprintf("Reloading environment variables. Found %d variables to reload", len(msg.EnvironmentVariables)))
 	var err error
 	for k, v := range msg.EnvironmentVariables {
 		os.Setenv(k, v)
 	}
 	if msg.FunctionAppDirectory != "" {
-		c.logger.Info(fmt.Sprintf("Changing current working directory to %s", msg.FunctionAppDirectory))
+		c.systemLogger().Info(fmt.Sprintf("Changing current working directory to %s", msg.FunctionAppDirectory))
 		err = os.Chdir(msg.FunctionAppDirectory)
 	}
 	c.stream.Send(&rpc.StreamingMessage{
@@ -179,6 +483,26 @@ type stackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
+type causer interface {
+	Cause() error
+}
+
+// errorStackTrace returns stack trace recorded closest to the origin of error, following
+// causes of errors wrapped with github.com/pkg/errors
+func errorStackTrace(err error) (st errors.StackTrace, ok bool) {
+	for err != nil {
+		if tracer, isTracer := err.(stackTracer); isTracer {
+			st, ok = tracer.StackTrace(), true
+		}
+		cause, isCauser := err.(causer)
+		if !isCauser {
+			break
+		}
+		err = cause.Cause()
+	}
+	return
+}
+
 func (c *channel) getStatus(err error) *rpc.StatusResult {
 	status := &rpc.StatusResult{
 		Status: rpc.StatusResult_Success,
@@ -188,8 +512,8 @@ func (c *channel) getStatus(err error) *rpc.StatusResult {
 		status.Exception = &rpc.RpcException{
 			Message: err.Error(),
 		}
-		if st, ok := err.(stackTracer); ok {
-			status.Exception.StackTrace = fmt.Sprintf("%+v", st.StackTrace())
+		if st, ok := errorStackTrace(err); ok {
+			status.Exception.StackTrace = fmt.Sprintf("%+v", st)
 		}
 	}
 	return status
@@ -197,17 +521,66 @@ func (c *channel) getStatus(err error) *rpc.StatusResult {
 
 func (c *channel) SetEventStream(stream Sender) {
 	c.stream = stream
-	c.logger = Logger{
-		Stream: stream,
-		Cat:    rpc.RpcLog_System,
+}
+
+func (c *channel) systemLogger() Logger {
+	return Logger{
+		Stream:   c.stream,
+		Cat:      rpc.RpcLog_System,
+		MinLevel: c.hostInfo.LogLevel(WorkerLogCategory),
+	}
+}
+
+func (c *channel) SetHealth(health *Health) {
+	if health != nil {
+		c.health = health
+	}
+}
+
+func (c *channel) SetMetrics(metrics *Metrics) {
+	if metrics != nil {
+		c.metrics = metrics
+		c.loaderLock.Lock()
+		c.loader.Metrics = metrics
+		c.loaderLock.Unlock()
+	}
+}
+
+func (c *channel) SetMiddleware(middleware []Middleware, functionMiddleware map[string][]Middleware) {
+	c.middleware = middleware
+	c.functionMiddleware = functionMiddleware
+}
+
+func (c *channel) SetHostInfo(hostInfo *HostInfo) {
+	if hostInfo != nil {
+		c.hostInfo = hostInfo
 	}
 }
 
 func (c *channel) SetLoader(loader Loader) {
+	c.loaderLock.Lock()
+	if loader.Metrics == nil {
+		loader.Metrics = c.metrics
+	}
 	c.loader = loader
+	c.loaderLock.Unlock()
 }
 
 // NewChannel create new default channel
 func NewChannel() Channel {
-	return &channel{}
+	return &channel{
+		health:   &Health{},
+		metrics:  &Metrics{},
+		hostInfo: &HostInfo{},
+	}
+}
+
+func invocationStatus(status rpc.StatusResult_Status) string {
+	switch status {
+	case rpc.StatusResult_Success:
+		return InvocationSucceeded
+	case rpc.StatusResult_Cancelled:
+		return InvocationCancelled
+	}
+	return InvocationFailed
 }
@@ -8,7 +8,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
@@ -41,21 +43,31 @@ func TestDefaultChannel(t *testing.T) {
 		LoadedFunctions: map[string]worker.Function{},
 	})
 	ch.InitRequest("mockRequestID", &rpc.WorkerInitRequest{})
-	mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
-		RequestId: "mockRequestID",
-		Content: &rpc.StreamingMessage_WorkerInitResponse{
-			WorkerInitResponse: &rpc.WorkerInitResponse{
-				Result: &rpc.StatusResult{
-					Status: rpc.StatusResult_Success,
-				},
-				Capabilities: map[string]string{
-					"RpcHttpTriggerMetadataRemoved": "true",
-					"RpcHttpBodyOnly":               "true",
-					"RawHttpBodyBytes":              "true",
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		msg, ok := v.(*rpc.StreamingMessage)
+		if !ok || msg.GetWorkerInitResponse() == nil {
+			return false
+		}
+		resp := msg.GetWorkerInitResponse()
+		// worker version depends on build info
+		resp.WorkerVersion = ""
+		return assert.Equal(t, &rpc.StreamingMessage{
+			RequestId: "mockRequestID",
+			Content: &rpc.StreamingMessage_WorkerInitResponse{
+				WorkerInitResponse: &rpc.WorkerInitResponse{
+					Result: &rpc.StatusResult{
+						Status: rpc.StatusResult_Success,
+					},
+					Capabilities: map[string]string{
+						"RpcHttpTriggerMetadataRemoved": "true",
+						"RpcHttpBodyOnly":               "true",
+						"RawHttpBodyBytes":              "true",
+						"TypedDataCollection":           "true",
+					},
 				},
 			},
-		},
-	})
+		}, msg)
+	}))
 	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
 		FunctionId: "mockFunctionID",
 		Metadata: &rpc.RpcFunctionMetadata{
@@ -262,3 +274,540 @@ func TestFunctionEnvironmentReloadRequest(t *testing.T) {
 	os.Chdir(pwd)
 	os.Remove(nwd)
 }
+
+func loadTestChannelFunction(t *testing.T, functionType reflect.Type) (worker.Channel, *mocks.Sender) {
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(functionType, nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+	})
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata: &rpc.RpcFunctionMetadata{
+			Name: "func",
+			Bindings: map[string]*rpc.BindingInfo{
+				"trigger": &rpc.BindingInfo{
+					Type:      "httpTrigger",
+					Direction: rpc.BindingInfo_in,
+				},
+			},
+		},
+	})
+	return ch, &mockSender
+}
+
+var mockHTTPInvocationRequest = &rpc.InvocationRequest{
+	FunctionId:   "mockFunctionID",
+	InvocationId: "mockInvocationID",
+	InputData: []*rpc.ParameterBinding{
+		&rpc.ParameterBinding{
+			Name: "trigger",
+			Data: &rpc.TypedData{
+				Data: &rpc.TypedData_Http{
+					Http: &rpc.RpcHttp{},
+				},
+			},
+		},
+	},
+}
+
+var blockingFuncStarted = make(chan struct{}, 1)
+
+type MockBlockingFuncForChannel map[string]interface{}
+
+func (m MockBlockingFuncForChannel) Run(ctx context.Context, logger api.Logger) {
+	blockingFuncStarted <- struct{}{}
+	<-ctx.Done()
+}
+
+func TestInvocationCancel(t *testing.T) {
+	ch, mockSender := loadTestChannelFunction(t, reflect.TypeOf((*MockBlockingFuncForChannel)(nil)).Elem())
+	done := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(done)
+	}()
+	<-blockingFuncStarted
+	ch.InvocationCancel("mockCancelRequestID", &rpc.InvocationCancel{
+		InvocationId: "mockInvocationID",
+		GracePeriod: &duration.Duration{
+			Nanos: int32(time.Millisecond * 10),
+		},
+	})
+	<-done
+	mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+		RequestId: "mockRequestID",
+		Content: &rpc.StreamingMessage_InvocationResponse{
+			InvocationResponse: &rpc.InvocationResponse{
+				InvocationId: "mockInvocationID",
+				Result: &rpc.StatusResult{
+					Status: rpc.StatusResult_Cancelled,
+					Result: "invocation cancelled by host",
+				},
+			},
+		},
+	})
+}
+
+func TestTerminateCancelsRunningInvocations(t *testing.T) {
+	ch, mockSender := loadTestChannelFunction(t, reflect.TypeOf((*MockBlockingFuncForChannel)(nil)).Elem())
+	done := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(done)
+	}()
+	<-blockingFuncStarted
+	ch.Terminate("mockTerminateRequestID", &rpc.WorkerTerminate{})
+	<-done
+	mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+		RequestId: "mockRequestID",
+		Content: &rpc.StreamingMessage_InvocationResponse{
+			InvocationResponse: &rpc.InvocationResponse{
+				InvocationId: "mockInvocationID",
+				Result: &rpc.StatusResult{
+					Status: rpc.StatusResult_Cancelled,
+					Result: "invocation cancelled by host",
+				},
+			},
+		},
+	})
+}
+
+func TestTerminateShortensPendingCancel(t *testing.T) {
+	ch, mockSender := loadTestChannelFunction(t, reflect.TypeOf((*MockBlockingFuncForChannel)(nil)).Elem())
+	done := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(done)
+	}()
+	<-blockingFuncStarted
+	ch.InvocationCancel("mockCancelRequestID", &rpc.InvocationCancel{
+		InvocationId: "mockInvocationID",
+		GracePeriod: &duration.Duration{
+			Seconds: 3600,
+		},
+	})
+	ch.Terminate("mockTerminateRequestID", &rpc.WorkerTerminate{
+		GracePeriod: &duration.Duration{
+			Nanos: int32(time.Millisecond * 10),
+		},
+	})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("terminate did not shorten pending cancellation")
+	}
+	mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+		RequestId: "mockRequestID",
+		Content: &rpc.StreamingMessage_InvocationResponse{
+			InvocationResponse: &rpc.InvocationResponse{
+				InvocationId: "mockInvocationID",
+				Result: &rpc.StatusResult{
+					Status: rpc.StatusResult_Cancelled,
+					Result: "invocation cancelled by host",
+				},
+			},
+		},
+	})
+}
+
+type MockOriginalFuncForChannel map[string]interface{}
+
+func (m MockOriginalFuncForChannel) Run(ctx context.Context, logger api.Logger) interface{} {
+	return "original"
+}
+
+type MockReloadedFuncForChannel map[string]interface{}
+
+func (m MockReloadedFuncForChannel) Run(ctx context.Context, logger api.Logger) interface{} {
+	return "reloaded"
+}
+
+type mockReloader struct {
+	mocks.TypeLoader
+}
+
+func (m *mockReloader) ReloadFunctionType(fi worker.FunctionInfo, logger api.Logger) (reflect.Type, error) {
+	ret := m.Called(fi, logger)
+	t, _ := ret.Get(0).(reflect.Type)
+	return t, ret.Error(1)
+}
+
+func TestFileChangeEventRequest(t *testing.T) {
+	originalType := reflect.TypeOf((*MockOriginalFuncForChannel)(nil)).Elem()
+	reloadedType := reflect.TypeOf((*MockReloadedFuncForChannel)(nil)).Elem()
+	data := []struct {
+		typeLoader     worker.TypeLoader
+		expectedReturn string
+		expected       interface{}
+	}{
+		{
+			typeLoader: func() worker.TypeLoader {
+				var mockLoader mocks.TypeLoader
+				mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(originalType, nil)
+				return &mockLoader
+			}(),
+			expectedReturn: "original",
+			expected: &rpc.WorkerActionResponse{
+				Action: rpc.WorkerActionResponse_Restart,
+				Reason: "function func cannot be reloaded: type loader does not support reloading functions: worker restart required",
+			},
+		},
+		{
+			typeLoader: func() worker.TypeLoader {
+				var mockLoader mockReloader
+				mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(originalType, nil)
+				mockLoader.On("ReloadFunctionType", mock.Anything, mock.Anything).Return(reloadedType, nil)
+				return &mockLoader
+			}(),
+			expectedReturn: "reloaded",
+			expected: &rpc.WorkerActionResponse{
+				Action: rpc.WorkerActionResponse_Reload,
+				Reason: "function func reloaded",
+			},
+		},
+		{
+			typeLoader: func() worker.TypeLoader {
+				var mockLoader mockReloader
+				mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(originalType, nil)
+				mockLoader.On("ReloadFunctionType", mock.Anything, mock.Anything).Return(nil, errors.New("build failed"))
+				return &mockLoader
+			}(),
+			expectedReturn: "original",
+			expected:       nil,
+		},
+	}
+	for _, tt := range data {
+		done := make(chan struct{})
+		var mockSender mocks.Sender
+		mockSender.On("Send", mock.MatchedBy(func(v interface{}) bool {
+			switch content := v.(*rpc.StreamingMessage).Content.(type) {
+			case *rpc.StreamingMessage_WorkerActionResponse:
+				return true
+			case *rpc.StreamingMessage_RpcLog:
+				return content.RpcLog.Level == rpc.RpcLog_Error
+			}
+			return false
+		})).Run(func(mock.Arguments) {
+			close(done)
+		})
+		mockSender.On("Send", mock.Anything)
+		loader := worker.Loader{
+			TypeLoader:      tt.typeLoader,
+			LoadedFunctions: map[string]worker.Function{},
+		}
+		ch := worker.NewChannel()
+		ch.SetEventStream(&mockSender)
+		ch.SetLoader(loader)
+		ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+			FunctionId: "mockFunctionID",
+			Metadata: &rpc.RpcFunctionMetadata{
+				Name:      "func",
+				Directory: filepath.Join("app", "func"),
+				Bindings: map[string]*rpc.BindingInfo{
+					"trigger": &rpc.BindingInfo{
+						Type:      "httpTrigger",
+						Direction: rpc.BindingInfo_in,
+					},
+				},
+			},
+		})
+		ch.FileChangeEventRequest("mockRequestID", &rpc.FileChangeEventRequest{
+			Type:     rpc.FileChangeEventRequest_Changed,
+			FullPath: filepath.Join("app", "func", "function.go"),
+		})
+		<-done
+		if tt.expected != nil {
+			mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+				RequestId: "mockRequestID",
+				Content: &rpc.StreamingMessage_WorkerActionResponse{
+					WorkerActionResponse: tt.expected.(*rpc.WorkerActionResponse),
+				},
+			})
+		}
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+			RequestId: "mockRequestID",
+			Content: &rpc.StreamingMessage_InvocationResponse{
+				InvocationResponse: &rpc.InvocationResponse{
+					InvocationId: "mockInvocationID",
+					OutputData:   []*rpc.ParameterBinding{},
+					ReturnValue: &rpc.TypedData{
+						Data: &rpc.TypedData_Http{
+							Http: &rpc.RpcHttp{
+								StatusCode: "200",
+								Body: &rpc.TypedData{
+									Data: &rpc.TypedData_String_{
+										String_: tt.expectedReturn,
+									},
+								},
+							},
+						},
+					},
+					Result: &rpc.StatusResult{
+						Status: rpc.StatusResult_Success,
+					},
+				},
+			},
+		})
+	}
+}
+
+// runningFuncHooks are injected into MockRunningFunc, so that each test controls it's own invocations
+type runningFuncHooks struct {
+	started chan struct{}
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newRunningFuncHooks() *runningFuncHooks {
+	return &runningFuncHooks{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+		closed:  make(chan struct{}, 1),
+	}
+}
+
+type MockRunningFunc struct {
+	Hooks *runningFuncHooks `azfunc:"inject"`
+}
+
+func (m *MockRunningFunc) Run(ctx context.Context, logger api.Logger) interface{} {
+	m.Hooks.started <- struct{}{}
+	<-m.Hooks.release
+	return "original"
+}
+
+func (m *MockRunningFunc) Close() error {
+	m.Hooks.closed <- struct{}{}
+	return nil
+}
+
+func TestFileChangeEventRequestDoesNotWaitForRunningInvocations(t *testing.T) {
+	var mockLoader mockReloader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockRunningFunc)(nil)), nil)
+	mockLoader.On("ReloadFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockReloadedFuncForChannel)(nil)).Elem(), nil)
+	reloaded := make(chan struct{})
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.MatchedBy(func(v interface{}) bool {
+		_, ok := v.(*rpc.StreamingMessage).Content.(*rpc.StreamingMessage_WorkerActionResponse)
+		return ok
+	})).Run(func(mock.Arguments) {
+		close(reloaded)
+	})
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	hooks := newRunningFuncHooks()
+	services := api.NewServices()
+	services.Register(hooks)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+		Services:        services,
+	})
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata: &rpc.RpcFunctionMetadata{
+			Name:      "func",
+			Directory: filepath.Join("app", "func"),
+			Bindings: map[string]*rpc.BindingInfo{
+				"trigger": &rpc.BindingInfo{
+					Type:      "httpTrigger",
+					Direction: rpc.BindingInfo_in,
+				},
+			},
+		},
+	})
+	running := *mockHTTPInvocationRequest
+	running.InvocationId = "mockRunningInvocationID"
+	done := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", &running)
+		close(done)
+	}()
+	<-hooks.started
+	ch.FileChangeEventRequest("mockRequestID", &rpc.FileChangeEventRequest{
+		Type:     rpc.FileChangeEventRequest_Changed,
+		FullPath: filepath.Join("app", "func", "function.go"),
+	})
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second * 5):
+		t.Fatal("reload waited for running invocation")
+	}
+	// new invocations use reloaded function while previous version is still running
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		resp := v.(*rpc.StreamingMessage).GetInvocationResponse()
+		return resp != nil && resp.InvocationId == "mockInvocationID" &&
+			resp.ReturnValue.GetHttp().GetBody().GetString_() == "reloaded"
+	}))
+	assert.Len(t, hooks.closed, 0)
+	close(hooks.release)
+	<-done
+	select {
+	case <-hooks.closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("previous version was not closed after it's invocation finished")
+	}
+}
+
+func TestFunctionLoadRequestDoesNotBlockInvocations(t *testing.T) {
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.MatchedBy(func(fi worker.FunctionInfo) bool {
+		return fi.Name == "slow"
+	}), mock.Anything).Run(func(mock.Arguments) {
+		close(loading)
+		<-release
+	}).Return(reflect.TypeOf((*MockReloadedFuncForChannel)(nil)).Elem(), nil)
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockOriginalFuncForChannel)(nil)).Elem(), nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+	})
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata:   mockHTTPFunctionMetadata,
+	})
+	slowMetadata := *mockHTTPFunctionMetadata
+	slowMetadata.Name = "slow"
+	loaded := make(chan struct{})
+	go func() {
+		ch.FunctionLoadRequest("mockSlowRequestID", &rpc.FunctionLoadRequest{
+			FunctionId: "mockSlowFunctionID",
+			Metadata:   &slowMetadata,
+		})
+		close(loaded)
+	}()
+	<-loading
+	invoked := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(invoked)
+	}()
+	select {
+	case <-invoked:
+	case <-time.After(time.Second * 5):
+		t.Fatal("invocation waited for function load")
+	}
+	close(release)
+	<-loaded
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		resp := v.(*rpc.StreamingMessage).GetFunctionLoadResponse()
+		return resp != nil && resp.FunctionId == "mockSlowFunctionID" && resp.Result.Status == rpc.StatusResult_Success
+	}))
+}
+
+func TestRepeatedFunctionLoadRequestWaitsForRunningInvocations(t *testing.T) {
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockRunningFunc)(nil)), nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	hooks := newRunningFuncHooks()
+	services := api.NewServices()
+	services.Register(hooks)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+		Services:        services,
+	})
+	load := &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata:   mockHTTPFunctionMetadata,
+	}
+	ch.FunctionLoadRequest("mockRequestID", load)
+	done := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(done)
+	}()
+	<-hooks.started
+	ch.FunctionLoadRequest("mockRequestID", load)
+	assert.Len(t, hooks.closed, 0)
+	close(hooks.release)
+	<-done
+	select {
+	case <-hooks.closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("previous version was not closed after it's invocation finished")
+	}
+}
+
+type MockErrorFuncForChannel map[string]interface{}
+
+func failingOperation() error {
+	return errors.New("operation failed")
+}
+
+func (m MockErrorFuncForChannel) Run(ctx context.Context, logger api.Logger) error {
+	return errors.Wrap(failingOperation(), "function failed")
+}
+
+func TestInvocationError(t *testing.T) {
+	mockFunctionType := reflect.TypeOf((*MockErrorFuncForChannel)(nil)).Elem()
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(mockFunctionType, nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+	})
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata: &rpc.RpcFunctionMetadata{
+			Name: "func",
+			Bindings: map[string]*rpc.BindingInfo{
+				"trigger": &rpc.BindingInfo{
+					Type:      "httpTrigger",
+					Direction: rpc.BindingInfo_in,
+				},
+			},
+		},
+	})
+	ch.InvocationRequest("mockRequestID", &rpc.InvocationRequest{
+		FunctionId:   "mockFunctionID",
+		InvocationId: "mockInvocationID",
+		InputData: []*rpc.ParameterBinding{
+			&rpc.ParameterBinding{
+				Name: "trigger",
+				Data: &rpc.TypedData{
+					Data: &rpc.TypedData_Http{
+						Http: &rpc.RpcHttp{},
+					},
+				},
+			},
+		},
+	})
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		msg, ok := v.(*rpc.StreamingMessage)
+		if !ok {
+			return false
+		}
+		resp, ok := msg.Content.(*rpc.StreamingMessage_InvocationResponse)
+		if !ok {
+			return false
+		}
+		result := resp.InvocationResponse.Result
+		return assert.Equal(t, rpc.StatusResult_Failure, result.Status) &&
+			assert.Equal(t, "function failed: operation failed", result.Exception.Message) &&
+			assert.Equal(t, "func", result.Exception.Source) &&
+			assert.Contains(t, result.Exception.StackTrace, "worker_test.failingOperation")
+	}))
+}
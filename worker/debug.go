@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// debugServer serves worker diagnostics on a local address
+type debugServer struct {
+	srv *http.Server
+}
+
+func newDebugServer(addr string, health *Health, metrics *Metrics) *debugServer {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/status", health)
+	mux.Handle("/metrics", metrics)
+	return &debugServer{
+		srv: &http.Server{
+			Addr:    addr,
+			Handler: mux,
+		},
+	}
+}
+
+func (d *debugServer) Start() {
+	go func() {
+		if err := d.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println(err)
+		}
+	}()
+}
+
+func (d *debugServer) Stop() {
+	d.srv.Close()
+}
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+)
+
+// DefaultMaxConcurrency is a default limit of invocations running at the same time in worker
+const DefaultMaxConcurrency = 100
+
+// dispatcher runs invocations on a bounded pool of goroutines.
+//
+// Each invocation first acquires a slot for its function and then a slot in the global
+// pool, so invocations waiting for a busy function do not hold global slots.
+type dispatcher struct {
+	global           chan struct{}
+	perFunctionLimit int
+	lock             sync.Mutex
+	functions        map[string]chan struct{}
+	wg               sync.WaitGroup
+	health           *Health
+}
+
+func newDispatcher(maxConcurrency, maxFunctionConcurrency int, health *Health) *dispatcher {
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	if maxFunctionConcurrency <= 0 || maxFunctionConcurrency > maxConcurrency {
+		maxFunctionConcurrency = maxConcurrency
+	}
+	return &dispatcher{
+		global:           make(chan struct{}, maxConcurrency),
+		perFunctionLimit: maxFunctionConcurrency,
+		functions:        make(map[string]chan struct{}),
+		health:           health,
+	}
+}
+
+func (d *dispatcher) functionSlots(functionID string) chan struct{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	slots, ok := d.functions[functionID]
+	if !ok {
+		slots = make(chan struct{}, d.perFunctionLimit)
+		d.functions[functionID] = slots
+	}
+	return slots
+}
+
+// invocationQueue is implemented by channels that track invocations waiting for a slot,
+// for instance to cancel them before they start
+type invocationQueue interface {
+	InvocationQueued(requestID string, msg *rpc.InvocationRequest)
+}
+
+// Dispatch schedules invocation on channel without blocking the caller
+func (d *dispatcher) Dispatch(ch Channel, requestID string, msg *rpc.InvocationRequest) {
+	if queue, ok := ch.(invocationQueue); ok {
+		queue.InvocationQueued(requestID, msg)
+	}
+	slots := d.functionSlots(msg.GetFunctionId())
+	d.wg.Add(1)
+	d.health.invocationQueued()
+	go func() {
+		defer d.wg.Done()
+		slots <- struct{}{}
+		d.global <- struct{}{}
+		d.health.invocationDequeued()
+		defer func() {
+			<-d.global
+			<-slots
+		}()
+		ch.InvocationRequest(requestID, msg)
+	}()
+}
+
+// Wait blocks until all dispatched invocations finish
+func (d *dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// WaitTimeout blocks until all dispatched invocations finish or timeout passes.
+// Returns false on timeout.
+func (d *dispatcher) WaitTimeout(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -3,13 +3,20 @@ package worker
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
+// DefaultDialTimeout is the time default grpc event stream waits for connection to host
+const DefaultDialTimeout = time.Second * 30
+
 // Request in event stream
 type Request struct {
 	ID string
@@ -19,21 +26,32 @@ var closeSend = &rpc.StreamingMessage{}
 
 // eventStream handler for azure functions
 type eventStream struct {
-	host                 string
-	port                 string
-	writer               chan *rpc.StreamingMessage
-	writerCh             chan chan *rpc.StreamingMessage
-	reader               chan *rpc.StreamingMessage
-	readerCh             chan chan *rpc.StreamingMessage
-	client               rpc.FunctionRpc_EventStreamClient
-	clientCancel         context.CancelFunc
+	host         string
+	port         string
+	writer       chan *rpc.StreamingMessage
+	writerCh     chan chan *rpc.StreamingMessage
+	sendDone     chan struct{}
+	reader       chan *rpc.StreamingMessage
+	readerCh     chan chan *rpc.StreamingMessage
+	client       rpc.FunctionRpc_EventStreamClient
+	clientCancel context.CancelFunc
+	// clientReplaced is closed when client is replaced after reconnect or when stream gives up
+	clientReplaced       chan struct{}
 	lock                 sync.Mutex
 	conn                 *grpc.ClientConn
 	grpcMaxMessageLength int
+	dialTimeout          time.Duration
+	keepalive            *keepalive.ClientParameters
+	credentials          credentials.TransportCredentials
+	reconnect            ReconnectPolicy
+	onStateChange        func(ConnectionState, error)
+	startStream          *rpc.StreamingMessage
+	stopCtx              context.Context
+	stopCancel           context.CancelFunc
 }
 
-func (e *eventStream) closeConn() {
-	e.lock.Lock()
+// closeClient must be called with lock held
+func (e *eventStream) closeClient() {
 	if e.clientCancel != nil {
 		e.clientCancel()
 		e.clientCancel = nil
@@ -42,6 +60,11 @@ func (e *eventStream) closeConn() {
 		e.conn.Close()
 		e.conn = nil
 	}
+}
+
+func (e *eventStream) closeConn() {
+	e.lock.Lock()
+	e.closeClient()
 	e.lock.Unlock()
 	wr, ok := <-e.writerCh
 	if ok {
@@ -56,13 +79,155 @@ func (e *eventStream) getClient() rpc.FunctionRpc_EventStreamClient {
 	return client
 }
 
+func (e *eventStream) setState(state ConnectionState, err error) {
+	if e.onStateChange != nil {
+		e.onStateChange(state, err)
+	}
+}
+
+func (e *eventStream) dial(ctx context.Context) (conn *grpc.ClientConn, client rpc.FunctionRpc_EventStreamClient, cancel context.CancelFunc, err error) {
+	dialTimeout := e.dialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	dialCtx, dialCancel := context.WithTimeout(ctx, dialTimeout)
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if e.credentials != nil {
+		opts = append(opts, grpc.WithTransportCredentials(e.credentials))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	if e.keepalive != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*e.keepalive))
+	}
+	if e.grpcMaxMessageLength > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(e.grpcMaxMessageLength),
+			grpc.MaxCallSendMsgSize(e.grpcMaxMessageLength),
+		))
+	}
+	conn, err = grpc.DialContext(dialCtx, e.host+":"+e.port, opts...)
+	dialCancel()
+	if err == nil {
+		var clientCtx context.Context
+		clientCtx, cancel = context.WithCancel(context.Background())
+		client, err = rpc.NewFunctionRpcClient(conn).EventStream(clientCtx)
+		if err != nil {
+			cancel()
+			conn.Close()
+		}
+	}
+	return
+}
+
+// clientSender sends messages directly through client and keeps the first error
+type clientSender struct {
+	client rpc.FunctionRpc_EventStreamClient
+	err    error
+}
+
+func (c *clientSender) Send(msg *rpc.StreamingMessage) {
+	if c.err == nil {
+		c.err = c.client.Send(msg)
+	}
+}
+
+// restartStream sends StartStream, if it was already sent by worker, on a new client
+// before any other message
+func (e *eventStream) restartStream(client rpc.FunctionRpc_EventStreamClient, attempts int, cause error) error {
+	e.lock.Lock()
+	startStream := e.startStream
+	e.lock.Unlock()
+	sender := &clientSender{client: client}
+	if startStream != nil {
+		sender.Send(startStream)
+	}
+	Logger{
+		Stream: sender,
+		Cat:    rpc.RpcLog_System,
+	}.Warn(fmt.Sprintf("Worker reconnected to host after %d attempts, connection was lost: %v", attempts, cause))
+	return sender.err
+}
+
+// replaceClient swaps client after reconnect, nil client means stream gave up
+func (e *eventStream) replaceClient(conn *grpc.ClientConn, client rpc.FunctionRpc_EventStreamClient, cancel context.CancelFunc) {
+	e.lock.Lock()
+	e.closeClient()
+	e.conn, e.client, e.clientCancel = conn, client, cancel
+	close(e.clientReplaced)
+	e.clientReplaced = make(chan struct{})
+	e.lock.Unlock()
+}
+
+// reconnectClient is called by receiver after connection is broken. It returns
+// new client or false if stream should be closed.
+func (e *eventStream) reconnectClient(cause error) (rpc.FunctionRpc_EventStreamClient, bool) {
+	e.lock.Lock()
+	stopCtx := e.stopCtx
+	e.lock.Unlock()
+	if stopCtx.Err() != nil {
+		e.replaceClient(nil, nil, nil)
+		return nil, false
+	}
+	// host ended the stream
+	if cause == io.EOF || !e.reconnect.enabled() {
+		fmt.Println(cause)
+		e.replaceClient(nil, nil, nil)
+		e.setState(Disconnected, cause)
+		return nil, false
+	}
+	e.setState(Reconnecting, cause)
+	err := cause
+	for attempt := 0; e.reconnect.canRetry(attempt); attempt++ {
+		select {
+		case <-time.After(e.reconnect.backoff(attempt)):
+		case <-stopCtx.Done():
+			e.replaceClient(nil, nil, nil)
+			return nil, false
+		}
+		conn, client, cancel, dialErr := e.dial(stopCtx)
+		if dialErr == nil {
+			if dialErr = e.restartStream(client, attempt+1, cause); dialErr != nil {
+				cancel()
+				conn.Close()
+			}
+		}
+		if dialErr == nil {
+			e.replaceClient(conn, client, cancel)
+			e.setState(Reconnected, nil)
+			return client, true
+		}
+		err = dialErr
+	}
+	fmt.Println(errors.Wrap(err, "could not reconnect to host"))
+	e.replaceClient(nil, nil, nil)
+	e.setState(Disconnected, err)
+	return nil, false
+}
+
+// nextClient waits until failed client is replaced by receiver. Returns false if stream gave up.
+func (e *eventStream) nextClient(failed rpc.FunctionRpc_EventStreamClient) (rpc.FunctionRpc_EventStreamClient, bool) {
+	for {
+		e.lock.Lock()
+		client, replaced := e.client, e.clientReplaced
+		e.lock.Unlock()
+		if client != failed {
+			return client, client != nil
+		}
+		<-replaced
+	}
+}
+
 func (e *eventStream) send(client rpc.FunctionRpc_EventStreamClient) {
 	defer func() {
 		close(e.writerCh)
+		if client != nil {
+			client.CloseSend()
+		}
 		e.closeConn()
+		close(e.sendDone)
 	}()
-	var err error
-	for err == nil {
+	for {
 		e.writerCh <- e.writer
 		msg, ok := <-e.writer
 		if !ok {
@@ -71,15 +236,30 @@ func (e *eventStream) send(client rpc.FunctionRpc_EventStreamClient) {
 		if msg == closeSend {
 			return
 		}
-		err = client.Send(msg)
-	}
-	if err != nil {
-		fmt.Println(err)
+		for err := client.Send(msg); err != nil; err = client.Send(msg) {
+			// io.EOF means that stream was broken by server and message can be sent again
+			// on a new connection, other errors are caused by message itself
+			if err != io.EOF {
+				fmt.Println(err)
+			}
+			if client, ok = e.nextClient(client); !ok || err != io.EOF {
+				break
+			}
+		}
+		if !ok {
+			return
+		}
 	}
 }
 
 // Send a message through event stream
 func (e *eventStream) Send(msg *rpc.StreamingMessage) {
+	if _, ok := msg.Content.(*rpc.StreamingMessage_StartStream); ok {
+		// StartStream is sent again after reconnect
+		e.lock.Lock()
+		e.startStream = msg
+		e.lock.Unlock()
+	}
 	writer, ok := <-e.writerCh
 	if ok {
 		writer <- msg
@@ -97,54 +277,58 @@ func (e *eventStream) recv(client rpc.FunctionRpc_EventStreamClient) {
 		close(e.reader)
 		e.closeConn()
 	}()
-	var err error
-	for err == nil {
-		var msg *rpc.StreamingMessage
-		msg, err = client.Recv()
+	for {
+		msg, err := client.Recv()
 		if err == nil {
 			e.reader <- msg
+			continue
+		}
+		var ok bool
+		if client, ok = e.reconnectClient(err); !ok {
+			return
 		}
 	}
-	if err != nil {
-		fmt.Println(err)
+}
+
+// flush waits until all messages passed to Send are written to client
+func (e *eventStream) flush() {
+	wr, ok := <-e.writerCh
+	if ok {
+		// closeSend is handled by sender after all previously sent messages
+		wr <- closeSend
 	}
+	<-e.sendDone
 }
 
+// Stop flushes pending messages and closes the connection. Stream is not reconnected
+// after Stop is called.
 func (e *eventStream) Stop() {
+	e.lock.Lock()
+	if e.stopCancel != nil {
+		e.stopCancel()
+	}
+	e.lock.Unlock()
+	e.flush()
 	e.closeConn()
+	// unblock receiver if there are unread messages
+	for range e.reader {
+	}
 }
 
 func (e *eventStream) Start() (err error) {
-	if err == nil {
-		e.lock.Lock()
-		if e.clientCancel != nil {
-			e.clientCancel()
-		}
-		if e.conn != nil {
-			e.conn.Close()
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock()}
-		if e.grpcMaxMessageLength > 0 {
-			opts = append(opts, grpc.WithDefaultCallOptions(
-				grpc.MaxCallRecvMsgSize(e.grpcMaxMessageLength),
-				grpc.MaxCallSendMsgSize(e.grpcMaxMessageLength),
-			))
-		}
-		e.conn, err = grpc.DialContext(ctx, e.host+":"+e.port, opts...)
-		cancel()
-		if err == nil {
-			ctx, cancel := context.WithCancel(context.Background())
-			e.client, err = rpc.NewFunctionRpcClient(e.conn).EventStream(ctx)
-			e.clientCancel = cancel
-		}
-		e.lock.Unlock()
-	}
+	e.lock.Lock()
+	e.closeClient()
+	e.stopCtx, e.stopCancel = context.WithCancel(context.Background())
+	e.conn, e.client, e.clientCancel, err = e.dial(e.stopCtx)
+	e.clientReplaced = make(chan struct{})
+	e.lock.Unlock()
 	if err == nil {
 		e.reader = make(chan *rpc.StreamingMessage)
 		e.writer = make(chan *rpc.StreamingMessage)
 		e.writerCh = make(chan chan *rpc.StreamingMessage)
+		e.sendDone = make(chan struct{})
 		client := e.getClient()
+		e.setState(Connected, nil)
 		go e.recv(client)
 		go e.send(client)
 	}
@@ -163,6 +347,32 @@ func (m MaxGrpcMessageLengthEventStreamOption) withEventStream(s *eventStream) {
 	s.grpcMaxMessageLength = int(m)
 }
 
+// DialTimeoutEventStreamOption sets the time default grpc event stream waits for connection to host.
+// Defaults to DefaultDialTimeout.
+type DialTimeoutEventStreamOption time.Duration
+
+func (d DialTimeoutEventStreamOption) withEventStream(s *eventStream) {
+	s.dialTimeout = time.Duration(d)
+}
+
+// KeepaliveEventStreamOption enables keepalive pings on default grpc event stream
+type KeepaliveEventStreamOption keepalive.ClientParameters
+
+func (k KeepaliveEventStreamOption) withEventStream(s *eventStream) {
+	params := keepalive.ClientParameters(k)
+	s.keepalive = &params
+}
+
+// CredentialsEventStreamOption sets transport credentials used by default grpc event stream.
+// Connection is insecure if credentials are not set.
+type CredentialsEventStreamOption struct {
+	credentials.TransportCredentials
+}
+
+func (c CredentialsEventStreamOption) withEventStream(s *eventStream) {
+	s.credentials = c.TransportCredentials
+}
+
 // HostEventStreamOption sets host option on default grpc event stream
 type HostEventStreamOption string
 
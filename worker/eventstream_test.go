@@ -0,0 +1,223 @@
+package worker_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type recordingRPCServer struct {
+	*grpc.Server
+	received chan *rpc.StreamingMessage
+}
+
+func (r *recordingRPCServer) EventStream(ev rpc.FunctionRpc_EventStreamServer) error {
+	for {
+		msg, err := ev.Recv()
+		if err != nil {
+			return err
+		}
+		r.received <- msg
+	}
+}
+
+func startRecordingServer(t *testing.T, address string) *recordingRPCServer {
+	var lis net.Listener
+	var err error
+	// port might not be released by previous server yet
+	for i := 0; i < 10; i++ {
+		if lis, err = net.Listen("tcp", address); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &recordingRPCServer{
+		Server:   grpc.NewServer(),
+		received: make(chan *rpc.StreamingMessage, 10),
+	}
+	rpc.RegisterFunctionRpcServer(srv.Server, srv)
+	go srv.Serve(lis)
+	return srv
+}
+
+func nextReceived(t *testing.T, srv *recordingRPCServer) *rpc.StreamingMessage {
+	select {
+	case msg := <-srv.received:
+		return msg
+	case <-time.After(time.Second * 5):
+		t.Fatal("message not received")
+	}
+	return nil
+}
+
+type stateRecorder struct {
+	lock   sync.Mutex
+	states []worker.ConnectionState
+}
+
+func (s *stateRecorder) record(state worker.ConnectionState, err error) {
+	s.lock.Lock()
+	s.states = append(s.states, state)
+	s.lock.Unlock()
+}
+
+func (s *stateRecorder) get() []worker.ConnectionState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]worker.ConnectionState{}, s.states...)
+}
+
+func TestEventStreamReconnects(t *testing.T) {
+	srv := startRecordingServer(t, "127.0.0.1:1235")
+	var states stateRecorder
+	stream := worker.NewEventStream(
+		worker.HostEventStreamOption("127.0.0.1"),
+		worker.PortEventStreamOption("1235"),
+		worker.ReconnectPolicy{
+			MaxAttempts:    50,
+			InitialBackoff: time.Millisecond * 10,
+			MaxBackoff:     time.Millisecond * 100,
+		},
+		worker.ConnectionStateEventStreamOption(states.record),
+	)
+	assert.NoError(t, stream.Start())
+	defer stream.Stop()
+	startStream := &rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_StartStream{
+			StartStream: &rpc.StartStream{
+				WorkerId: "mockWorkerID",
+			},
+		},
+	}
+	stream.Send(startStream)
+	assert.Equal(t, "mockWorkerID", nextReceived(t, srv).GetStartStream().GetWorkerId())
+	srv.Stop()
+	srv = startRecordingServer(t, "127.0.0.1:1235")
+	defer srv.Stop()
+	assert.Equal(t, "mockWorkerID", nextReceived(t, srv).GetStartStream().GetWorkerId())
+	log := nextReceived(t, srv).GetRpcLog()
+	if assert.NotNil(t, log) {
+		assert.Equal(t, rpc.RpcLog_Warning, log.Level)
+		assert.Equal(t, "System", log.Category)
+		assert.Contains(t, log.Message, "Worker reconnected to host")
+	}
+	stream.Send(&rpc.StreamingMessage{
+		RequestId: "mockRequestID",
+		Content: &rpc.StreamingMessage_WorkerHeartbeat{
+			WorkerHeartbeat: &rpc.WorkerHeartbeat{},
+		},
+	})
+	assert.Equal(t, "mockRequestID", nextReceived(t, srv).RequestId)
+	assert.Equal(t, []worker.ConnectionState{
+		worker.Connected,
+		worker.Reconnecting,
+		worker.Reconnected,
+	}, states.get())
+}
+
+func TestEventStreamStopInterruptsReconnect(t *testing.T) {
+	srv := startRecordingServer(t, "127.0.0.1:1235")
+	var states stateRecorder
+	stream := worker.NewEventStream(
+		worker.HostEventStreamOption("127.0.0.1"),
+		worker.PortEventStreamOption("1235"),
+		worker.ReconnectPolicy{
+			MaxAttempts:    -1,
+			InitialBackoff: time.Millisecond * 10,
+		},
+		worker.ConnectionStateEventStreamOption(states.record),
+	)
+	assert.NoError(t, stream.Start())
+	stream.Send(&rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_StartStream{
+			StartStream: &rpc.StartStream{},
+		},
+	})
+	nextReceived(t, srv)
+	srv.Stop()
+	// message held until stream reconnects or gives up
+	sent := make(chan struct{})
+	go func() {
+		stream.Send(&rpc.StreamingMessage{})
+		close(sent)
+	}()
+	time.Sleep(time.Millisecond * 100)
+	stopped := make(chan struct{})
+	go func() {
+		stream.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatal("stream not stopped")
+	}
+	<-sent
+	_, ok := stream.Recv()
+	assert.False(t, ok)
+	assert.Equal(t, []worker.ConnectionState{
+		worker.Connected,
+		worker.Reconnecting,
+	}, states.get())
+}
+
+func TestEventStreamGivesUpReconnecting(t *testing.T) {
+	srv := startRecordingServer(t, "127.0.0.1:1235")
+	var states stateRecorder
+	stream := worker.NewEventStream(
+		worker.HostEventStreamOption("127.0.0.1"),
+		worker.PortEventStreamOption("1235"),
+		worker.DialTimeoutEventStreamOption(time.Millisecond*50),
+		worker.ReconnectPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond * 10,
+		},
+		worker.ConnectionStateEventStreamOption(states.record),
+	)
+	assert.NoError(t, stream.Start())
+	stream.Send(&rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_StartStream{
+			StartStream: &rpc.StartStream{},
+		},
+	})
+	nextReceived(t, srv)
+	srv.Stop()
+	for _, ok := stream.Recv(); ok; _, ok = stream.Recv() {
+	}
+	// messages sent after stream gave up are dropped
+	stream.Send(&rpc.StreamingMessage{})
+	stream.Stop()
+	assert.Equal(t, []worker.ConnectionState{
+		worker.Connected,
+		worker.Reconnecting,
+		worker.Disconnected,
+	}, states.get())
+}
+
+func TestReconnectPolicyDisabledByDefault(t *testing.T) {
+	srv := startRecordingServer(t, "127.0.0.1:1235")
+	var states stateRecorder
+	stream := worker.NewEventStream(
+		worker.HostEventStreamOption("127.0.0.1"),
+		worker.PortEventStreamOption("1235"),
+		worker.ConnectionStateEventStreamOption(states.record),
+	)
+	assert.NoError(t, stream.Start())
+	srv.Stop()
+	for _, ok := stream.Recv(); ok; _, ok = stream.Recv() {
+	}
+	stream.Stop()
+	assert.Equal(t, []worker.ConnectionState{
+		worker.Connected,
+		worker.Disconnected,
+	}, states.get())
+}
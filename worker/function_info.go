@@ -3,7 +3,9 @@ package worker
 import (
 	"go/token"
 	"strings"
+	"time"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/function"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/pkg/errors"
@@ -60,14 +62,61 @@ type FunctionInfo struct {
 	Trigger            BindingInfo
 	InputBindings      Bindings
 	OutputBindings     Bindings
+	// Timeout of function invocation, 0 means no timeout. Read by Loader from functionTimeout
+	// in function.json or, if not defined there, from host.json.
+	Timeout time.Duration
 }
 
-func isTrigger(typ string) bool {
-	switch typ {
-	case string(function.HTTPTrigger):
-		return true
-	default:
-		return false
+func (d Direction) String() string {
+	switch d {
+	case Out:
+		return api.DirectionOut
+	case InOut:
+		return api.DirectionInOut
+	}
+	return api.DirectionIn
+}
+
+func (d DataType) String() string {
+	switch d {
+	case String:
+		return api.DataTypeString
+	case Binary:
+		return api.DataTypeBinary
+	case Stream:
+		return api.DataTypeStream
+	}
+	return ""
+}
+
+func (b BindingInfo) apiBindingInfo() api.BindingInfo {
+	return api.BindingInfo{
+		Type:      b.Type,
+		Direction: b.Direction.String(),
+		DataType:  b.DataType.String(),
+	}
+}
+
+func (b Bindings) apiBindings() api.Bindings {
+	bindings := make(api.Bindings, len(b))
+	for k, v := range b {
+		bindings[k] = v.apiBindingInfo()
+	}
+	return bindings
+}
+
+// apiFunctionInfo returns function info as seen by user functions
+func (fi FunctionInfo) apiFunctionInfo(functionID string) api.FunctionInfo {
+	return api.FunctionInfo{
+		ID:                 functionID,
+		Name:               fi.Name,
+		Directory:          fi.Directory,
+		ScriptFile:         fi.ScriptFile,
+		EntryPoint:         fi.EntryPoint,
+		TriggerBindingName: fi.TriggerBindingName,
+		Trigger:            fi.Trigger.apiBindingInfo(),
+		InputBindings:      fi.InputBindings.apiBindings(),
+		OutputBindings:     fi.OutputBindings.apiBindings(),
 	}
 }
 
@@ -115,7 +164,7 @@ func NewFunctionInfo(metadata *rpc.RpcFunctionMetadata) (FunctionInfo, error) {
 		case rpc.BindingInfo_stream:
 			b.DataType = Stream
 		}
-		if isTrigger(b.Type) {
+		if function.IsTrigger(b.Type) {
 			fi.TriggerBindingName = k
 			b.Direction = In
 			fi.Trigger = b
@@ -1,11 +1,18 @@
 package worker_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/graphql-editor/azure-functions-golang-worker/worker"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestNewFunctionInfo(t *testing.T) {
@@ -87,6 +94,26 @@ func TestNewFunctionInfo(t *testing.T) {
 	}, fi)
 }
 
+func TestNewFunctionInfoDetectsTriggers(t *testing.T) {
+	fi, err := worker.NewFunctionInfo(&rpc.RpcFunctionMetadata{
+		Bindings: map[string]*rpc.BindingInfo{
+			"msg": &rpc.BindingInfo{
+				Type:      "queueTrigger",
+				Direction: rpc.BindingInfo_in,
+			},
+			"out": &rpc.BindingInfo{
+				Type:      "queue",
+				Direction: rpc.BindingInfo_out,
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "msg", fi.TriggerBindingName)
+	assert.Equal(t, worker.BindingInfo{Type: "queueTrigger", Direction: worker.In}, fi.Trigger)
+	assert.Empty(t, fi.InputBindings)
+	assert.Contains(t, fi.OutputBindings, "out")
+}
+
 func TestEntrypointValidation(t *testing.T) {
 	fi, err := worker.NewFunctionInfo(&rpc.RpcFunctionMetadata{
 		EntryPoint: "entryPoint",
@@ -98,3 +125,54 @@ func TestEntrypointValidation(t *testing.T) {
 	})
 	assert.Error(t, err)
 }
+
+func TestLoaderFunctionTimeout(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "function-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(appDir)
+	hostJSON := filepath.Join(appDir, "host.json")
+	ioutil.WriteFile(hostJSON, []byte(`{"version": "2.0", "functionTimeout": "00:05:00"}`), 0644)
+	for _, dir := range []string{"default", "override", "invalid", "unparsable", "unlimited"} {
+		os.Mkdir(filepath.Join(appDir, dir), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(appDir, "override", "function.json"), []byte(`{"functionTimeout": "1.00:00:01.5"}`), 0644)
+	ioutil.WriteFile(filepath.Join(appDir, "invalid", "function.json"), []byte(`{"functionTimeout": "5 minutes"}`), 0644)
+	ioutil.WriteFile(filepath.Join(appDir, "unparsable", "function.json"), []byte(`{"functionTimeout":`), 0644)
+	ioutil.WriteFile(filepath.Join(appDir, "unlimited", "function.json"), []byte(`{"functionTimeout": "-1"}`), 0644)
+	data := []struct {
+		dir      string
+		expected time.Duration
+		warn     bool
+	}{
+		{dir: "default", expected: time.Minute * 5},
+		{dir: "override", expected: time.Hour*24 + time.Millisecond*1500},
+		{dir: "unlimited"},
+		{dir: "invalid", warn: true},
+		{dir: "unparsable", warn: true},
+	}
+	for _, tt := range data {
+		var mockLoader mocks.TypeLoader
+		mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockFunction)(nil)).Elem(), nil)
+		var mockLogger mocks.Logger
+		mockLogger.On("Warn", mock.Anything)
+		loader := worker.Loader{
+			TypeLoader:      &mockLoader,
+			LoadedFunctions: make(map[string]worker.Function),
+		}
+		// invalid timeout is reported and function is loaded without timeout
+		assert.NoError(t, loader.Load("mockID", &rpc.RpcFunctionMetadata{
+			Name:      tt.dir,
+			Directory: filepath.Join(appDir, tt.dir),
+		}, &mockLogger), tt.dir)
+		fi, err := loader.Info("mockID")
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, fi.Timeout, tt.dir)
+		if tt.warn {
+			mockLogger.AssertNumberOfCalls(t, "Warn", 1)
+		} else {
+			mockLogger.AssertNotCalled(t, "Warn", mock.Anything)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package worker
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/function"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 )
 
@@ -15,6 +19,24 @@ type TypeLoader interface {
 	GetFunctionType(FunctionInfo, api.Logger) (reflect.Type, error)
 }
 
+// Reloader is implemented by type loaders that can load a new version of a function
+// after it's source has changed. Functions loaded by type loaders that do not
+// implement Reloader can only be updated by restarting worker.
+type Reloader interface {
+	ReloadFunctionType(FunctionInfo, api.Logger) (reflect.Type, error)
+}
+
+// ServiceProvider is implemented by type loaders that register services of loaded functions,
+// for example services registered by go plugin of a function. Services are registered on a copy
+// of loader services, after function type is loaded.
+type ServiceProvider interface {
+	RegisterServices(FunctionInfo, *api.Services) error
+}
+
+// ErrRestartRequired is returned, possibly wrapped, when function cannot be reloaded
+// without restarting worker.
+var ErrRestartRequired = errors.New("worker restart required")
+
 // Function is loaded function in worker
 type Function struct {
 	Info       FunctionInfo
@@ -25,6 +47,10 @@ type Function struct {
 type Loader struct {
 	TypeLoader
 	LoadedFunctions map[string]Function
+	// Metrics records time it takes to load function types, if set
+	Metrics *Metrics
+	// Services injected into function fields tagged with `azfunc:"inject"`
+	Services *api.Services
 }
 
 // Info returns function info for given function id
@@ -45,41 +71,119 @@ func (l *Loader) Func(functionID string) (function.ObjectType, error) {
 	return f.ObjectType, nil
 }
 
-// Load returns object type for given function id
+// Load loads function and stores it in loader under given function id. Previously loaded
+// version of function is replaced but not closed, as invocations may still be using it.
 func (l *Loader) Load(functionID string, metadata *rpc.RpcFunctionMetadata, logger api.Logger) error {
+	f, err := l.LoadFunction(metadata, logger)
+	if err == nil {
+		l.LoadedFunctions[functionID] = f
+	}
+	return err
+}
+
+// LoadFunction loads and initializes function. Returned function is not stored in loader.
+func (l *Loader) LoadFunction(metadata *rpc.RpcFunctionMetadata, logger api.Logger) (Function, error) {
 	info, err := NewFunctionInfo(metadata)
 	if err != nil {
-		return err
+		return Function{}, err
 	}
+	// invalid timeout does not prevent function from loading, same as invalid log levels in host.json
+	if info.Timeout, err = functionTimeout(info.Directory); err != nil {
+		logger.Warn(fmt.Sprintf("Worker was unable to read functionTimeout of function %s, function runs without timeout: %v", info.Name, err))
+	}
+	start := time.Now()
 	t, err := l.GetFunctionType(info, logger)
 	if err != nil {
+		return Function{}, err
+	}
+	l.Metrics.functionBuilt(info.Name, time.Since(start))
+	f, err := newFunction(info, t)
+	if err == nil {
+		err = l.initFunction(&f, logger)
+	}
+	return f, err
+}
+
+// Reload loads a new version of already loaded function. Returned function is not stored in loader.
+func (l *Loader) Reload(info FunctionInfo, logger api.Logger) (Function, error) {
+	reloader, ok := l.TypeLoader.(Reloader)
+	if !ok {
+		return Function{}, errors.Wrap(ErrRestartRequired, "type loader does not support reloading functions")
+	}
+	start := time.Now()
+	t, err := reloader.ReloadFunctionType(info, logger)
+	if err != nil {
+		return Function{}, err
+	}
+	l.Metrics.functionBuilt(info.Name, time.Since(start))
+	f, err := newFunction(info, t)
+	if err == nil {
+		err = l.initFunction(&f, logger)
+	}
+	return f, err
+}
+
+// CloseFunctions closes all loaded functions that implement io.Closer
+func (l *Loader) CloseFunctions() error {
+	var err error
+	for _, f := range l.LoadedFunctions {
+		if closeErr := f.ObjectType.Close(); closeErr != nil {
+			err = multierror.Append(err, errors.Wrapf(closeErr, "could not close function %s", f.Info.Name))
+		}
+	}
+	return err
+}
+
+// initFunction injects services into function and initializes it
+func (l *Loader) initFunction(f *Function, logger api.Logger) error {
+	services := l.Services
+	if provider, ok := l.TypeLoader.(ServiceProvider); ok {
+		services = services.Clone()
+		if err := provider.RegisterServices(f.Info, services); err != nil {
+			return errors.Wrap(err, "could not register function services")
+		}
+	}
+	if err := f.ObjectType.Inject(services); err != nil {
 		return err
 	}
+	return f.ObjectType.Init(context.Background(), logger)
+}
+
+func closeFunction(f Function, logger api.Logger) {
+	if err := f.ObjectType.Close(); err != nil {
+		logger.Error(fmt.Sprintf("Could not close function %s: %v", f.Info.Name, err))
+	}
+}
+
+func newFunction(info FunctionInfo, t reflect.Type) (Function, error) {
 	inputBindings := make(function.Bindings, 0, len(info.InputBindings))
 	for k, v := range info.InputBindings {
 		inputBindings = append(inputBindings, function.Binding{
-			Name: k,
-			Type: v.Type,
+			Name:     k,
+			Type:     v.Type,
+			DataType: v.DataType.String(),
 		})
 	}
 	outputBindings := make(function.Bindings, 0, len(info.OutputBindings))
 	for k, v := range info.OutputBindings {
 		outputBindings = append(outputBindings, function.Binding{
-			Name: k,
-			Type: v.Type,
+			Name:     k,
+			Type:     v.Type,
+			DataType: v.DataType.String(),
 		})
 	}
-	ot, err := function.NewObjectType(
+	ot, err := function.NewTriggerObjectType(
 		t,
-		function.TriggerType(info.Trigger.Type),
+		function.Binding{
+			Name:     info.TriggerBindingName,
+			Type:     info.Trigger.Type,
+			DataType: info.Trigger.DataType.String(),
+		},
 		inputBindings,
 		outputBindings,
 	)
-	if err == nil {
-		l.LoadedFunctions[functionID] = Function{
-			Info:       info,
-			ObjectType: ot,
-		}
-	}
-	return err
+	return Function{
+		Info:       info,
+		ObjectType: ot,
+	}, err
 }
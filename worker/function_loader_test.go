@@ -102,3 +102,151 @@ func TestMissingFunctionErrors(t *testing.T) {
 	_, err = loader.Func("mockID")
 	assert.Error(t, err)
 }
+
+type mockResource struct {
+	closed int
+}
+
+type MockInitFunction struct {
+	Resource *mockResource
+}
+
+func (m *MockInitFunction) Init(ctx context.Context, logger api.Logger) error {
+	if m.Resource != nil {
+		return errors.New("function initialized twice")
+	}
+	m.Resource = &mockResource{}
+	return nil
+}
+
+func (m *MockInitFunction) Close() error {
+	m.Resource.closed++
+	return nil
+}
+
+func (m *MockInitFunction) Run(ctx context.Context, logger api.Logger) {}
+
+type MockFailingInitFunction map[string]interface{}
+
+func (m MockFailingInitFunction) Init(ctx context.Context, logger api.Logger) error {
+	return errors.New("missing connection string")
+}
+
+func (m MockFailingInitFunction) Run(ctx context.Context, logger api.Logger) {}
+
+var mockHTTPFunctionMetadata = &rpc.RpcFunctionMetadata{
+	Name: "func",
+	Bindings: map[string]*rpc.BindingInfo{
+		"trigger": &rpc.BindingInfo{
+			Type:      "httpTrigger",
+			Direction: rpc.BindingInfo_in,
+		},
+	},
+}
+
+func TestLoaderInitializesAndClosesFunctions(t *testing.T) {
+	var mockLoader mockReloader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockInitFunction)(nil)), nil)
+	mockLoader.On("ReloadFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockInitFunction)(nil)), nil)
+	loader := worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: make(map[string]worker.Function),
+	}
+	assert.NoError(t, loader.Load("mockID", mockHTTPFunctionMetadata, nil))
+	f := loader.LoadedFunctions["mockID"]
+	obj := f.ObjectType.New()
+	resource := obj.Interface().(*MockInitFunction).Resource
+	if !assert.NotNil(t, resource) {
+		return
+	}
+	reloaded, err := loader.Reload(f.Info, nil)
+	assert.NoError(t, err)
+	reloadedObj := reloaded.ObjectType.New()
+	assert.False(t, resource == reloadedObj.Interface().(*MockInitFunction).Resource)
+	assert.NoError(t, loader.CloseFunctions())
+	assert.Equal(t, 1, resource.closed)
+}
+
+func TestInitErrorFailsFunctionLoad(t *testing.T) {
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockFailingInitFunction)(nil)).Elem(), nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+	})
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata:   mockHTTPFunctionMetadata,
+	})
+	resp := mockSender.Calls[len(mockSender.Calls)-1].Arguments.Get(0).(*rpc.StreamingMessage).GetFunctionLoadResponse()
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, rpc.StatusResult_Failure, resp.Result.Status)
+		assert.Equal(t, "function initialization failed: missing connection string", resp.Result.Exception.Message)
+	}
+}
+
+type mockDatabase struct {
+	name string
+}
+
+type MockInjectedFunction struct {
+	DB     *mockDatabase `azfunc:"inject"`
+	Config string        `azfunc:"inject"`
+}
+
+func (m *MockInjectedFunction) Run(ctx context.Context, logger api.Logger) {}
+
+type mockServiceProvider struct {
+	mocks.TypeLoader
+}
+
+func (m *mockServiceProvider) RegisterServices(fi worker.FunctionInfo, services *api.Services) error {
+	ret := m.Called(fi, services)
+	if register, ok := ret.Get(0).(func(*api.Services)); ok {
+		register(services)
+	}
+	return ret.Error(1)
+}
+
+func TestLoaderInjectsServices(t *testing.T) {
+	db := &mockDatabase{name: "loader"}
+	services := api.NewServices()
+	services.Register(db)
+	services.Register("loader config")
+	var mockLoader mockServiceProvider
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockInjectedFunction)(nil)), nil)
+	mockLoader.On("RegisterServices", mock.Anything, mock.Anything).Return(func(services *api.Services) {
+		services.Register("function config")
+	}, nil)
+	loader := worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: make(map[string]worker.Function),
+		Services:        services,
+	}
+	assert.NoError(t, loader.Load("mockID", mockHTTPFunctionMetadata, nil))
+	loaded := loader.LoadedFunctions["mockID"]
+	obj := loaded.ObjectType.New()
+	f := obj.Interface().(*MockInjectedFunction)
+	assert.Same(t, db, f.DB)
+	assert.Equal(t, "function config", f.Config)
+	v, err := services.Resolve(reflect.TypeOf(""))
+	assert.NoError(t, err)
+	assert.Equal(t, "loader config", v.Interface(), "provider does not modify loader services")
+}
+
+func TestLoaderMissingServiceFailsFunctionLoad(t *testing.T) {
+	var mockLoader mockServiceProvider
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockInjectedFunction)(nil)), nil)
+	mockLoader.On("RegisterServices", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+	loader := worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: make(map[string]worker.Function),
+	}
+	assert.EqualError(t, loader.Load("mockID", mockHTTPFunctionMetadata, nil), "could not register function services: connection refused")
+	_, ok := loader.LoadedFunctions["mockID"]
+	assert.False(t, ok)
+}
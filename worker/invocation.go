@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+)
+
+// gracePeriod converts rpc duration to time.Duration, missing or invalid grace period is treated as 0
+func gracePeriod(d *duration.Duration) time.Duration {
+	gp, err := ptypes.Duration(d)
+	if err != nil || gp < 0 {
+		return 0
+	}
+	return gp
+}
+
+// invocation represents a single function call in progress
+type invocation struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	lock      sync.Mutex
+	timer     *time.Timer
+	deadline  time.Time
+	cancelled bool
+	// started is false while invocation waits in dispatcher queue
+	started bool
+}
+
+// requestCancel cancels invocation context after grace period. If cancellation is already
+// pending, the earlier of the two deadlines is kept.
+func (i *invocation) requestCancel(gracePeriod time.Duration) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.cancelled {
+		return
+	}
+	deadline := time.Now().Add(gracePeriod)
+	if i.timer != nil {
+		if !deadline.Before(i.deadline) {
+			return
+		}
+		i.timer.Stop()
+	}
+	i.deadline = deadline
+	i.timer = time.AfterFunc(gracePeriod, func() {
+		i.lock.Lock()
+		i.cancelled = true
+		i.lock.Unlock()
+		i.cancel()
+	})
+}
+
+// cancelQueued cancels invocation that has not started yet. Returns false if invocation
+// has already started.
+func (i *invocation) cancelQueued() bool {
+	i.lock.Lock()
+	if i.started {
+		i.lock.Unlock()
+		return false
+	}
+	i.cancelled = true
+	i.lock.Unlock()
+	i.cancel()
+	return true
+}
+
+// isCancelled returns true if invocation context was cancelled on host request
+func (i *invocation) isCancelled() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.cancelled
+}
+
+func (i *invocation) finish() {
+	i.lock.Lock()
+	if i.timer != nil {
+		i.timer.Stop()
+	}
+	i.lock.Unlock()
+	i.cancel()
+}
+
+// invocations tracks invocations in progress by invocation id
+type invocations struct {
+	lock     sync.Mutex
+	running  map[string]*invocation
+	closing  bool
+	deadline time.Time
+}
+
+// queue registers invocation waiting in dispatcher queue, so that it can be cancelled before it starts
+func (r *invocations) queue(invocationID string) *invocation {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.get(invocationID)
+}
+
+// get returns invocation with id, creating it if needed. Must be called with lock held.
+func (r *invocations) get(invocationID string) *invocation {
+	if inv, ok := r.running[invocationID]; ok {
+		return inv
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	inv := &invocation{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if r.running == nil {
+		r.running = make(map[string]*invocation)
+	}
+	r.running[invocationID] = inv
+	return inv
+}
+
+// start marks invocation as started, invocations cancelled while queued stay cancelled
+func (r *invocations) start(invocationID string) *invocation {
+	r.lock.Lock()
+	inv := r.get(invocationID)
+	closing, deadline := r.closing, r.deadline
+	r.lock.Unlock()
+	inv.lock.Lock()
+	inv.started = true
+	inv.lock.Unlock()
+	if closing {
+		inv.requestCancel(time.Until(deadline))
+	}
+	return inv
+}
+
+func (r *invocations) finish(invocationID string) {
+	r.lock.Lock()
+	inv, ok := r.running[invocationID]
+	delete(r.running, invocationID)
+	r.lock.Unlock()
+	if ok {
+		inv.finish()
+	}
+}
+
+// cancel requests cancellation of invocation context after grace period, queued invocations
+// are cancelled immediately. Returns false if invocation is neither queued nor running.
+func (r *invocations) cancel(invocationID string, gracePeriod time.Duration) bool {
+	r.lock.Lock()
+	inv, ok := r.running[invocationID]
+	r.lock.Unlock()
+	if ok && !inv.cancelQueued() {
+		inv.requestCancel(gracePeriod)
+	}
+	return ok
+}
+
+// cancelAll requests cancellation of all running invocations after grace period. Invocations
+// started after a call to cancelAll are cancelled at the same deadline.
+func (r *invocations) cancelAll(gracePeriod time.Duration) {
+	r.lock.Lock()
+	if !r.closing {
+		r.closing = true
+		r.deadline = time.Now().Add(gracePeriod)
+	}
+	running := make([]*invocation, 0, len(r.running))
+	for _, inv := range r.running {
+		running = append(running, inv)
+	}
+	r.lock.Unlock()
+	for _, inv := range running {
+		inv.requestCancel(gracePeriod)
+	}
+}
+
+// withTraceContext returns context with trace context of invocation, trace context
+// that is not a valid W3C trace context is ignored
+func withTraceContext(ctx context.Context, traceContext *rpc.RpcTraceContext) context.Context {
+	tc, err := api.ParseTraceParent(traceContext.GetTraceParent())
+	if err != nil {
+		return ctx
+	}
+	tc.TraceState = traceContext.GetTraceState()
+	tc.Attributes = traceContext.GetAttributes()
+	return api.ContextWithTraceContext(ctx, tc)
+}
+
+// withInvocationInfo returns context with info of invocation, it must be called
+// after trace context and deadline of invocation are set
+func withInvocationInfo(ctx context.Context, invocationID, functionID string, info FunctionInfo) context.Context {
+	tc, _ := api.GetTraceContext(ctx)
+	deadline, _ := ctx.Deadline()
+	return api.ContextWithInvocationInfo(ctx, api.InvocationInfo{
+		InvocationID: invocationID,
+		Function:     info.apiFunctionInfo(functionID),
+		TraceContext: tc,
+		Deadline:     deadline,
+	})
+}
@@ -0,0 +1,53 @@
+package worker_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+var invocationInfoFuncInfo = make(chan api.InvocationInfo, 1)
+
+type MockInvocationInfoFunc map[string]interface{}
+
+func (m MockInvocationInfoFunc) Run(ctx context.Context, logger api.Logger) {
+	info, ok := api.GetInvocationInfo(ctx)
+	if !ok {
+		panic("missing invocation info")
+	}
+	invocationInfoFuncInfo <- info
+}
+
+func TestInvocationInfo(t *testing.T) {
+	ch, _ := loadTestChannelFunction(t, reflect.TypeOf((*MockInvocationInfoFunc)(nil)).Elem())
+	ch.InvocationRequest("mockRequestID", &rpc.InvocationRequest{
+		FunctionId:   "mockFunctionID",
+		InvocationId: "mockInvocationID",
+		InputData:    mockHTTPInvocationRequest.InputData,
+		TraceContext: &rpc.RpcTraceContext{
+			TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		},
+	})
+	info := <-invocationInfoFuncInfo
+	assert.Equal(t, "mockInvocationID", info.InvocationID)
+	assert.Equal(t, api.FunctionInfo{
+		ID:                 "mockFunctionID",
+		Name:               "func",
+		EntryPoint:         "Function",
+		TriggerBindingName: "trigger",
+		Trigger: api.BindingInfo{
+			Type:      "httpTrigger",
+			Direction: api.DirectionIn,
+		},
+		InputBindings:  api.Bindings{},
+		OutputBindings: api.Bindings{},
+	}, info.Function)
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", info.TraceContext.TraceParent())
+	assert.True(t, info.Deadline.IsZero())
+	_, ok := api.GetInvocationInfo(context.Background())
+	assert.False(t, ok)
+}
@@ -1,30 +1,54 @@
 package worker
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/pkg/errors"
 )
 
 // Logger that sends logs through rpc
+//
+// Key value pairs of structured logs are sent as json object in RpcLog properties. Properties
+// of logs with key value pairs or with FunctionName set always include invocationId and functionName.
 type Logger struct {
 	InvocationID string
 	EventID      string
+	FunctionName string
 	Cat          rpc.RpcLog_RpcLogCategory
 	Stream       Sender
+	// MinLevel is the lowest level of messages sent to host, messages with lower
+	// level are discarded without formatting
+	MinLevel rpc.RpcLog_Level
+	attrs    []api.Attr
+}
+
+func (l Logger) enabled(level rpc.RpcLog_Level) bool {
+	return level >= l.MinLevel
 }
 
 // Trace logs current stack trace with message
 func (l Logger) Trace(msg string) {
+	if !l.enabled(rpc.RpcLog_Trace) {
+		return
+	}
+	l.log(withStack(msg, 1), rpc.RpcLog_Trace)
+}
+
+// withStack appends stack trace of caller to message, skip is the number of
+// frames between withStack and caller
+func withStack(msg string, skip int) string {
 	st := errors.WithStack(errors.New("")).(stackTracer).StackTrace()
-	msg = fmt.Sprintf("%s\n%+v", msg, st[1:])
-	l.log(msg, rpc.RpcLog_Trace)
+	return fmt.Sprintf("%s\n%+v", msg, st[skip+1:])
 }
 
 // Tracef logs current stack trace with message with formatted string
 func (l Logger) Tracef(sfmt string, args ...interface{}) {
-	l.Trace(fmt.Sprintf(sfmt, args...))
+	if l.enabled(rpc.RpcLog_Trace) {
+		l.Trace(fmt.Sprintf(sfmt, args...))
+	}
 }
 
 // Debug logs a debug level message
@@ -34,7 +58,9 @@ func (l Logger) Debug(msg string) {
 
 // Debugf logs current stack trace with message with formatted string
 func (l Logger) Debugf(sfmt string, args ...interface{}) {
-	l.Debug(fmt.Sprintf(sfmt, args...))
+	if l.enabled(rpc.RpcLog_Debug) {
+		l.Debug(fmt.Sprintf(sfmt, args...))
+	}
 }
 
 // Info logs an info level message
@@ -44,7 +70,9 @@ func (l Logger) Info(msg string) {
 
 // Infof logs current stack trace with message with formatted string
 func (l Logger) Infof(sfmt string, args ...interface{}) {
-	l.Info(fmt.Sprintf(sfmt, args...))
+	if l.enabled(rpc.RpcLog_Information) {
+		l.Info(fmt.Sprintf(sfmt, args...))
+	}
 }
 
 // Warn logs an warning level message
@@ -54,7 +82,9 @@ func (l Logger) Warn(msg string) {
 
 // Warnf logs current stack trace with message with formatted string
 func (l Logger) Warnf(sfmt string, args ...interface{}) {
-	l.Warn(fmt.Sprintf(sfmt, args...))
+	if l.enabled(rpc.RpcLog_Warning) {
+		l.Warn(fmt.Sprintf(sfmt, args...))
+	}
 }
 
 // Error logs an error level message
@@ -64,7 +94,9 @@ func (l Logger) Error(msg string) {
 
 // Errorf logs current stack trace with message with formatted string
 func (l Logger) Errorf(sfmt string, args ...interface{}) {
-	l.Error(fmt.Sprintf(sfmt, args...))
+	if l.enabled(rpc.RpcLog_Error) {
+		l.Error(fmt.Sprintf(sfmt, args...))
+	}
 }
 
 // Fatal logs an fatal level message
@@ -74,10 +106,113 @@ func (l Logger) Fatal(msg string) {
 
 // Fatalf logs current stack trace with message with formatted string
 func (l Logger) Fatalf(sfmt string, args ...interface{}) {
-	l.Fatal(fmt.Sprintf(sfmt, args...))
+	if l.enabled(rpc.RpcLog_Critical) {
+		l.Fatal(fmt.Sprintf(sfmt, args...))
+	}
 }
 
 func (l *Logger) log(msg string, level rpc.RpcLog_Level) {
+	l.logAttrs(msg, level, nil)
+}
+
+// With returns logger that attaches key value pair to all messages
+func (l Logger) With(key string, value interface{}) api.StructuredLogger {
+	l.attrs = append(l.attrs[:len(l.attrs):len(l.attrs)], api.Attr{Key: key, Value: value})
+	return l
+}
+
+// Enabled returns false if messages with level are discarded
+func (l Logger) Enabled(level api.Level) bool {
+	return l.enabled(rpc.RpcLog_Level(level))
+}
+
+// Log message with level and key value pairs
+func (l Logger) Log(level api.Level, msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Level(level), msg, 1, keysAndValues)
+}
+
+// Tracew logs current stack trace with message and key value pairs
+func (l Logger) Tracew(msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Trace, msg, 1, keysAndValues)
+}
+
+// Debugw logs a debug level message with key value pairs
+func (l Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Debug, msg, 1, keysAndValues)
+}
+
+// Infow logs an info level message with key value pairs
+func (l Logger) Infow(msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Information, msg, 1, keysAndValues)
+}
+
+// Warnw logs a warning level message with key value pairs
+func (l Logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Warning, msg, 1, keysAndValues)
+}
+
+// Errorw logs an error level message with key value pairs
+func (l Logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Error, msg, 1, keysAndValues)
+}
+
+// Fatalw logs a fatal level message with key value pairs
+func (l Logger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.logw(rpc.RpcLog_Critical, msg, 1, keysAndValues)
+}
+
+// logw skip is the number of frames between logw and caller
+func (l Logger) logw(level rpc.RpcLog_Level, msg string, skip int, keysAndValues []interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	if level == rpc.RpcLog_Trace {
+		msg = withStack(msg, skip+1)
+	}
+	l.logAttrs(msg, level, api.Attrs(keysAndValues...))
+}
+
+// propertyValue converts value to a value that can be serialized to json
+func propertyValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case error:
+		return vv.Error()
+	case json.Marshaler:
+		return vv
+	case fmt.Stringer:
+		return vv.String()
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return v
+}
+
+func (l *Logger) properties(attrs []api.Attr) string {
+	if len(l.attrs) == 0 && len(attrs) == 0 && l.FunctionName == "" {
+		return ""
+	}
+	props := map[string]interface{}{
+		"invocationId": l.InvocationID,
+		"functionName": l.FunctionName,
+	}
+	for _, attr := range l.attrs {
+		props[attr.Key] = propertyValue(attr.Value)
+	}
+	for _, attr := range attrs {
+		props[attr.Key] = propertyValue(attr.Value)
+	}
+	b, err := json.Marshal(props)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (l *Logger) logAttrs(msg string, level rpc.RpcLog_Level, attrs []api.Attr) {
+	if !l.enabled(level) {
+		return
+	}
 	l.Stream.Send(&rpc.StreamingMessage{
 		Content: &rpc.StreamingMessage_RpcLog{
 			RpcLog: &rpc.RpcLog{
@@ -86,6 +221,7 @@ func (l *Logger) log(msg string, level rpc.RpcLog_Level) {
 				Message:      msg,
 				Level:        level,
 				Category:     rpc.RpcLog_RpcLogCategory_name[int32(l.Cat)],
+				Properties:   l.properties(attrs),
 			},
 		},
 	})
@@ -1,9 +1,12 @@
 package worker_test
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
 	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/graphql-editor/azure-functions-golang-worker/worker"
@@ -109,3 +112,40 @@ func TestLogger(t *testing.T) {
 		},
 	})
 }
+
+func TestStructuredLogger(t *testing.T) {
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+
+	var logger api.StructuredLogger = worker.Logger{
+		InvocationID: "mockInvocationID",
+		EventID:      "mockEventID",
+		FunctionName: "mockFunction",
+		Cat:          rpc.RpcLog_User,
+		Stream:       &mockSender,
+		MinLevel:     rpc.RpcLog_Debug,
+	}
+	assert.False(t, logger.Enabled(api.TraceLevel))
+	assert.True(t, logger.Enabled(api.DebugLevel))
+	logger.Tracew("trace msg", "key", "value")
+	mockSender.AssertNotCalled(t, "Send", mock.Anything)
+	logger.With("user", "mockUser").Infow(
+		"info msg",
+		"count", 2,
+		"err", errors.New("failed"),
+		"duration", time.Second,
+	)
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		log := v.(*rpc.StreamingMessage).GetRpcLog()
+		return assert.Equal(t, "info msg", log.Message) &&
+			assert.Equal(t, rpc.RpcLog_Information, log.Level) &&
+			assert.JSONEq(t, `{
+				"invocationId": "mockInvocationID",
+				"functionName": "mockFunction",
+				"user": "mockUser",
+				"count": 2,
+				"err": "failed",
+				"duration": "1s"
+			}`, log.Properties)
+	}))
+}
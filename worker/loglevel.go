@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/pkg/errors"
+)
+
+// Log categories used to filter logs sent to host
+const (
+	// WorkerLogCategory is a category of worker system logs
+	WorkerLogCategory = "Worker"
+	// FunctionLogCategory is a prefix of categories of user function logs, function
+	// logs are in category Function.{FunctionName}
+	FunctionLogCategory = "Function"
+	// DefaultLogCategory is used for categories without level
+	DefaultLogCategory = "Default"
+)
+
+// LogLevels maps log categories to the lowest level of messages sent to host.
+// Categories are case insensitive.
+type LogLevels map[string]rpc.RpcLog_Level
+
+// Level returns level of category. If category has no level, level of the closest parent
+// category is used, where parent of category A.B is A. Categories without a level
+// default to DefaultLogCategory level or, if it is not defined, to rpc.RpcLog_Trace.
+func (l LogLevels) Level(category string) rpc.RpcLog_Level {
+	levels := make(map[string]rpc.RpcLog_Level, len(l))
+	for k, v := range l {
+		levels[strings.ToLower(k)] = v
+	}
+	category = strings.ToLower(category)
+	for category != "" {
+		if level, ok := levels[category]; ok {
+			return level
+		}
+		i := strings.LastIndex(category, ".")
+		if i == -1 {
+			break
+		}
+		category = category[:i]
+	}
+	if level, ok := levels[strings.ToLower(DefaultLogCategory)]; ok {
+		return level
+	}
+	return rpc.RpcLog_Trace
+}
+
+// ParseLogLevel parses level name used by host.json
+func ParseLogLevel(s string) (rpc.RpcLog_Level, error) {
+	for level, name := range rpc.RpcLog_Level_name {
+		if strings.EqualFold(name, s) {
+			return rpc.RpcLog_Level(level), nil
+		}
+	}
+	return 0, errors.Errorf("invalid log level %s", s)
+}
+
+type hostLoggingConfig struct {
+	Logging struct {
+		LogLevel map[string]string `json:"logLevel"`
+	} `json:"logging"`
+}
+
+// readHostLogLevels reads logging.logLevel property of host.json in function app directory
+func readHostLogLevels(appDirectory string) (LogLevels, error) {
+	path := filepath.Join(appDirectory, "host.json")
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg hostLoggingConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+	levels := make(LogLevels, len(cfg.Logging.LogLevel))
+	for category, name := range cfg.Logging.LogLevel {
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read %s", path)
+		}
+		levels[category] = level
+	}
+	return levels, nil
+}
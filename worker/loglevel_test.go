@@ -0,0 +1,126 @@
+package worker_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLogLevels(t *testing.T) {
+	levels := worker.LogLevels{
+		"default":         rpc.RpcLog_Warning,
+		"Function":        rpc.RpcLog_Information,
+		"Function.MyFunc": rpc.RpcLog_Debug,
+		"Function.Silent": rpc.RpcLog_None,
+		"Worker":          rpc.RpcLog_Error,
+	}
+	assert.Equal(t, rpc.RpcLog_Debug, levels.Level("function.myfunc"))
+	assert.Equal(t, rpc.RpcLog_Debug, levels.Level("Function.MyFunc.User"))
+	assert.Equal(t, rpc.RpcLog_None, levels.Level("Function.Silent"))
+	assert.Equal(t, rpc.RpcLog_Information, levels.Level("Function.Other"))
+	assert.Equal(t, rpc.RpcLog_Error, levels.Level("Worker"))
+	assert.Equal(t, rpc.RpcLog_Warning, levels.Level("Host"))
+	assert.Equal(t, rpc.RpcLog_Trace, worker.LogLevels{}.Level("Host"))
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := worker.ParseLogLevel("information")
+	assert.NoError(t, err)
+	assert.Equal(t, rpc.RpcLog_Information, level)
+	_, err = worker.ParseLogLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestLoggerDiscardsMessagesBelowMinLevel(t *testing.T) {
+	var mockSender mocks.Sender
+	logger := worker.Logger{
+		Cat:      rpc.RpcLog_User,
+		Stream:   &mockSender,
+		MinLevel: rpc.RpcLog_Warning,
+	}
+	logger.Trace("trace msg")
+	logger.Tracef("trace %s", "msg")
+	logger.Debug("debug msg")
+	logger.Infof("info %s", "msg")
+	mockSender.AssertNotCalled(t, "Send", mock.Anything)
+	mockSender.On("Send", mock.Anything)
+	logger.Warn("warn msg")
+	mockSender.AssertNumberOfCalls(t, "Send", 1)
+}
+
+type MockLoggingFuncForChannel map[string]interface{}
+
+func (m MockLoggingFuncForChannel) Run(ctx context.Context, logger api.Logger) {
+	logger.Debug("debug msg")
+	logger.Info("info msg")
+}
+
+func TestChannelFiltersLogsByCategory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "host.json"), []byte(`{
+		"version": "2.0",
+		"logging": {
+			"logLevel": {
+				"default": "Warning",
+				"Function.func": "Debug"
+			}
+		}
+	}`), 0644))
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockLoggingFuncForChannel)(nil)).Elem(), nil)
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.SetLoader(worker.Loader{
+		TypeLoader:      &mockLoader,
+		LoadedFunctions: map[string]worker.Function{},
+	})
+	ch.InitRequest("mockRequestID", &rpc.WorkerInitRequest{
+		LogCategories: map[string]rpc.RpcLog_Level{
+			"Function.func": rpc.RpcLog_Information,
+		},
+	})
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata: &rpc.RpcFunctionMetadata{
+			Name:      "func",
+			Directory: filepath.Join(dir, "func"),
+			Bindings: map[string]*rpc.BindingInfo{
+				"trigger": &rpc.BindingInfo{
+					Type:      "httpTrigger",
+					Direction: rpc.BindingInfo_in,
+				},
+			},
+		},
+	})
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	ch.InvocationCancel("mockRequestID", &rpc.InvocationCancel{
+		InvocationId: "notRunning",
+	})
+	var messages []string
+	for _, call := range mockSender.Calls {
+		if log := call.Arguments.Get(0).(*rpc.StreamingMessage).GetRpcLog(); log != nil {
+			messages = append(messages, log.Message)
+		}
+	}
+	// worker warning is sent, user debug log is discarded as host overrides host.json level
+	assert.Equal(t, []string{
+		"info msg",
+		"Invocation notRunning is not running and cannot be cancelled",
+	}, messages)
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+)
+
+// DefaultLogQueueSize is a default number of logs waiting to be sent to host
+const DefaultLogQueueSize = 1000
+
+// logBatchSize limits number of logs sent at once, so that responses do not wait for long batches
+const logBatchSize = 100
+
+// LogQueuePolicy decides what happens with a log when log queue is full
+type LogQueuePolicy int
+
+const (
+	// BlockOnFullLogQueue blocks logging until there is space in queue
+	BlockOnFullLogQueue LogQueuePolicy = iota
+	// DropOnFullLogQueue drops logs that do not fit in queue
+	DropOnFullLogQueue
+)
+
+type queuedLog struct {
+	seq          uint64
+	invocationID string
+	msg          *rpc.StreamingMessage
+}
+
+// logQueue sends logs to stream asynchronously in batches. Other messages are sent
+// synchronously and take priority over logs. Logs of an invocation are always sent before
+// it's response and system logs are always sent before other responses.
+type logQueue struct {
+	stream Sender
+	size   int
+	policy LogQueuePolicy
+	health *Health
+	*logQueueState
+}
+
+// logQueueState is guarded by lock
+type logQueueState struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	// sequence number of the last log queued and sent
+	queuedSeq, sentSeq uint64
+	queue              []queuedLog
+	// invocations maps invocation id to the sequence number of it's last log not yet sent
+	invocations map[string]uint64
+	// systemSeq is the sequence number of the last log not bound to invocation
+	systemSeq uint64
+	// responses is a number of messages being sent synchronously
+	responses int
+	closed    bool
+	done      chan struct{}
+}
+
+func newLogQueue(stream Sender, size int, policy LogQueuePolicy, health *Health) *logQueue {
+	if size <= 0 {
+		size = DefaultLogQueueSize
+	}
+	q := &logQueue{
+		stream: stream,
+		size:   size,
+		policy: policy,
+		health: health,
+		logQueueState: &logQueueState{
+			invocations: make(map[string]uint64),
+			done:        make(chan struct{}),
+		},
+	}
+	q.cond = sync.NewCond(&q.lock)
+	go q.run()
+	return q
+}
+
+// Send queues logs and sends other messages immediately. Logs of invocation are flushed
+// before invocation response is sent, system logs are flushed before other messages.
+func (q *logQueue) Send(msg *rpc.StreamingMessage) {
+	switch content := msg.Content.(type) {
+	case *rpc.StreamingMessage_RpcLog:
+		q.push(msg, content.RpcLog.GetInvocationId())
+		return
+	case *rpc.StreamingMessage_InvocationResponse:
+		q.flush(content.InvocationResponse.GetInvocationId())
+	default:
+		q.flushSystem()
+	}
+	q.lock.Lock()
+	q.responses++
+	q.lock.Unlock()
+	q.stream.Send(msg)
+	q.lock.Lock()
+	q.responses--
+	q.cond.Broadcast()
+	q.lock.Unlock()
+}
+
+func (q *logQueue) push(msg *rpc.StreamingMessage, invocationID string) {
+	q.lock.Lock()
+	if len(q.queue) >= q.size && !q.closed {
+		if q.policy == DropOnFullLogQueue {
+			q.lock.Unlock()
+			q.health.logDropped()
+			return
+		}
+		for len(q.queue) >= q.size && !q.closed {
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		// queue was already flushed, send log directly
+		q.lock.Unlock()
+		q.stream.Send(msg)
+		return
+	}
+	q.queuedSeq++
+	q.queue = append(q.queue, queuedLog{
+		seq:          q.queuedSeq,
+		invocationID: invocationID,
+		msg:          msg,
+	})
+	if invocationID != "" {
+		q.invocations[invocationID] = q.queuedSeq
+	} else {
+		q.systemSeq = q.queuedSeq
+	}
+	q.cond.Broadcast()
+	q.lock.Unlock()
+}
+
+// flush waits until all logs of invocation are sent
+func (q *logQueue) flush(invocationID string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	seq, ok := q.invocations[invocationID]
+	delete(q.invocations, invocationID)
+	for ok && q.sentSeq < seq {
+		q.cond.Wait()
+	}
+}
+
+// flushSystem waits until all logs not bound to invocation are sent
+func (q *logQueue) flushSystem() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.sentSeq < q.systemSeq {
+		q.cond.Wait()
+	}
+}
+
+func (q *logQueue) run() {
+	defer close(q.done)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		for (len(q.queue) == 0 || q.responses > 0) && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.queue) == 0 {
+			return
+		}
+		n := len(q.queue)
+		if n > logBatchSize {
+			n = logBatchSize
+		}
+		batch := make([]queuedLog, n)
+		copy(batch, q.queue)
+		q.queue = q.queue[n:]
+		q.cond.Broadcast()
+		q.lock.Unlock()
+		for _, log := range batch {
+			q.stream.Send(log.msg)
+		}
+		q.lock.Lock()
+		q.sentSeq = batch[n-1].seq
+		// forget invocations with all logs sent, including those logging after their response
+		for _, log := range batch {
+			if seq, ok := q.invocations[log.invocationID]; ok && seq <= q.sentSeq {
+				delete(q.invocations, log.invocationID)
+			}
+		}
+		q.cond.Broadcast()
+	}
+}
+
+// Close sends all queued logs, logs sent after Close are sent synchronously
+func (q *logQueue) Close() {
+	q.lock.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.lock.Unlock()
+	<-q.done
+}
@@ -0,0 +1,251 @@
+package worker_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// gatedEventStream blocks sending logs until gate is closed
+type gatedEventStream struct {
+	memoryEventStream
+	gate chan struct{}
+}
+
+func (g *gatedEventStream) Send(msg *rpc.StreamingMessage) {
+	if _, ok := msg.Content.(*rpc.StreamingMessage_RpcLog); ok {
+		<-g.gate
+	}
+	g.memoryEventStream.Send(msg)
+}
+
+func mockInvocationLog(invocationID string, i int) *rpc.StreamingMessage {
+	return &rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_RpcLog{
+			RpcLog: &rpc.RpcLog{
+				InvocationId: invocationID,
+				Message:      fmt.Sprintf("log %d", i),
+			},
+		},
+	}
+}
+
+// loggingChannel returns mock channel that sends logs and response of invocation
+// through sender passed by worker
+func loggingChannel(logs int) *MockChannel {
+	var sender worker.Sender
+	var mockChannel MockChannel
+	mockChannel.On("SetEventStream", mock.Anything).Run(func(args mock.Arguments) {
+		sender = args.Get(0).(worker.Sender)
+	})
+	mockChannel.On("SetLoader", mock.Anything)
+	mockChannel.On("SetHealth", mock.Anything)
+	mockChannel.On("SetMetrics", mock.Anything)
+	mockChannel.On("SetMiddleware", mock.Anything, mock.Anything)
+	mockChannel.On("SetHostInfo", mock.Anything)
+	mockChannel.On("InvocationRequest", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		invocationID := args.Get(1).(*rpc.InvocationRequest).InvocationId
+		for i := 0; i < logs; i++ {
+			sender.Send(mockInvocationLog(invocationID, i))
+		}
+		sender.Send(&rpc.StreamingMessage{
+			Content: &rpc.StreamingMessage_InvocationResponse{
+				InvocationResponse: &rpc.InvocationResponse{
+					InvocationId: invocationID,
+				},
+			},
+		})
+	})
+	mockChannel.On("Terminate", mock.Anything, mock.Anything)
+	return &mockChannel
+}
+
+func sendInvocationAndTerminate(stream *memoryEventStream, invocationID string) {
+	stream.recv <- &rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_InvocationRequest{
+			InvocationRequest: &rpc.InvocationRequest{
+				InvocationId: invocationID,
+			},
+		},
+	}
+	stream.recv <- &rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_WorkerTerminate{
+			WorkerTerminate: &rpc.WorkerTerminate{},
+		},
+	}
+}
+
+func TestWorkerSendsInvocationLogsBeforeResponse(t *testing.T) {
+	mockChannel := loggingChannel(50)
+	mockChannel.wg.Add(2)
+	stream := &memoryEventStream{
+		recv: make(chan *rpc.StreamingMessage),
+		sent: make(chan *rpc.StreamingMessage, 100),
+	}
+	w := worker.Worker{
+		Channel:      mockChannel,
+		WorkerID:     "mockWorkerID",
+		RequestID:    "mockRequestID",
+		Stream:       stream,
+		LogQueueSize: 10,
+	}
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- w.Listen()
+	}()
+	sendInvocationAndTerminate(stream, "mockInvocationID")
+	select {
+	case err := <-listenErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not exit after terminate")
+	}
+	mockChannel.wg.Wait()
+	close(stream.sent)
+	var logs []string
+	responded := false
+	for msg := range stream.sent {
+		switch content := msg.Content.(type) {
+		case *rpc.StreamingMessage_RpcLog:
+			assert.False(t, responded, "log sent after invocation response")
+			logs = append(logs, content.RpcLog.Message)
+		case *rpc.StreamingMessage_InvocationResponse:
+			responded = true
+		}
+	}
+	assert.True(t, responded)
+	if assert.Len(t, logs, 50) {
+		for i, msg := range logs {
+			assert.Equal(t, fmt.Sprintf("log %d", i), msg)
+		}
+	}
+	assert.Equal(t, 0, w.Health.Status().DroppedLogs)
+}
+
+func TestWorkerDropsLogsOnFullQueue(t *testing.T) {
+	mockChannel := loggingChannel(5)
+	mockChannel.wg.Add(2)
+	stream := &gatedEventStream{
+		memoryEventStream: memoryEventStream{
+			recv: make(chan *rpc.StreamingMessage),
+			sent: make(chan *rpc.StreamingMessage, 100),
+		},
+		gate: make(chan struct{}),
+	}
+	w := worker.Worker{
+		Channel:        mockChannel,
+		WorkerID:       "mockWorkerID",
+		RequestID:      "mockRequestID",
+		Stream:         stream,
+		Health:         &worker.Health{},
+		LogQueueSize:   1,
+		LogQueuePolicy: worker.DropOnFullLogQueue,
+	}
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- w.Listen()
+	}()
+	sendInvocationAndTerminate(&stream.memoryEventStream, "mockInvocationID")
+	// at most one log is being sent and one is queued, others are dropped
+	assert.Eventually(t, func() bool {
+		return w.Health.Status().DroppedLogs >= 3
+	}, time.Second, time.Millisecond)
+	close(stream.gate)
+	select {
+	case err := <-listenErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not exit after terminate")
+	}
+	mockChannel.wg.Wait()
+	close(stream.sent)
+	logs := 0
+	for msg := range stream.sent {
+		if _, ok := msg.Content.(*rpc.StreamingMessage_RpcLog); ok {
+			logs++
+		}
+	}
+	assert.Equal(t, 5, logs+w.Health.Status().DroppedLogs)
+}
+
+func TestWorkerSendsSystemLogsBeforeControlResponse(t *testing.T) {
+	var sender worker.Sender
+	var mockChannel MockChannel
+	mockChannel.On("SetEventStream", mock.Anything).Run(func(args mock.Arguments) {
+		sender = args.Get(0).(worker.Sender)
+	})
+	mockChannel.On("SetLoader", mock.Anything)
+	mockChannel.On("SetHealth", mock.Anything)
+	mockChannel.On("SetMetrics", mock.Anything)
+	mockChannel.On("SetMiddleware", mock.Anything, mock.Anything)
+	mockChannel.On("SetHostInfo", mock.Anything)
+	mockChannel.On("FunctionLoadRequest", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for i := 0; i < 5; i++ {
+			sender.Send(mockInvocationLog("", i))
+		}
+		sender.Send(&rpc.StreamingMessage{
+			Content: &rpc.StreamingMessage_FunctionLoadResponse{
+				FunctionLoadResponse: &rpc.FunctionLoadResponse{},
+			},
+		})
+	})
+	mockChannel.On("Terminate", mock.Anything, mock.Anything)
+	mockChannel.wg.Add(2)
+	stream := &gatedEventStream{
+		memoryEventStream: memoryEventStream{
+			recv: make(chan *rpc.StreamingMessage),
+			sent: make(chan *rpc.StreamingMessage, 100),
+		},
+		gate: make(chan struct{}),
+	}
+	w := worker.Worker{
+		Channel:   &mockChannel,
+		WorkerID:  "mockWorkerID",
+		RequestID: "mockRequestID",
+		Stream:    stream,
+	}
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- w.Listen()
+	}()
+	stream.recv <- &rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_FunctionLoadRequest{
+			FunctionLoadRequest: &rpc.FunctionLoadRequest{},
+		},
+	}
+	// logs are held back by stream, response must wait for them
+	time.AfterFunc(time.Millisecond*50, func() {
+		close(stream.gate)
+	})
+	stream.recv <- &rpc.StreamingMessage{
+		Content: &rpc.StreamingMessage_WorkerTerminate{
+			WorkerTerminate: &rpc.WorkerTerminate{},
+		},
+	}
+	select {
+	case err := <-listenErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not exit after terminate")
+	}
+	mockChannel.wg.Wait()
+	close(stream.sent)
+	logs := 0
+	responded := false
+	for msg := range stream.sent {
+		switch msg.Content.(type) {
+		case *rpc.StreamingMessage_RpcLog:
+			assert.False(t, responded, "system log sent after function load response")
+			logs++
+		case *rpc.StreamingMessage_FunctionLoadResponse:
+			responded = true
+		}
+	}
+	assert.True(t, responded)
+	assert.Equal(t, 5, logs)
+}
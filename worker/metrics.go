@@ -0,0 +1,346 @@
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+)
+
+// DefaultDurationBuckets are upper bounds, in seconds, of invocation duration histogram buckets
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are upper bounds, in bytes, of payload size histogram buckets
+var DefaultSizeBuckets = []float64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// Invocation statuses used as status label of function_invocations_total
+const (
+	InvocationSucceeded = "success"
+	InvocationFailed    = "failure"
+	InvocationCancelled = "cancelled"
+)
+
+// payloadSize returns size of data in bytes, numbers have a size of 8 bytes and
+// size of http data is the size of it's body
+func payloadSize(data *rpc.TypedData) int {
+	switch d := data.GetData().(type) {
+	case *rpc.TypedData_String_:
+		return len(d.String_)
+	case *rpc.TypedData_Json:
+		return len(d.Json)
+	case *rpc.TypedData_Bytes:
+		return len(d.Bytes)
+	case *rpc.TypedData_Stream:
+		return len(d.Stream)
+	case *rpc.TypedData_Http:
+		return payloadSize(d.Http.GetBody())
+	case *rpc.TypedData_Int, *rpc.TypedData_Double:
+		return 8
+	case *rpc.TypedData_CollectionBytes:
+		size := 0
+		for _, b := range d.CollectionBytes.GetBytes() {
+			size += len(b)
+		}
+		return size
+	case *rpc.TypedData_CollectionString:
+		size := 0
+		for _, s := range d.CollectionString.GetString_() {
+			size += len(s)
+		}
+		return size
+	case *rpc.TypedData_CollectionDouble:
+		return 8 * len(d.CollectionDouble.GetDouble())
+	case *rpc.TypedData_CollectionSint64:
+		return 8 * len(d.CollectionSint64.GetSint64())
+	}
+	return 0
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) histogram {
+	return histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// copy returns histogram with a copy of counts, buckets are never modified and are shared
+func (h histogram) copy() histogram {
+	h.counts = append([]uint64(nil), h.counts...)
+	return h
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type functionMetrics struct {
+	invocations  map[string]uint64
+	panics       uint64
+	inFlight     int
+	duration     histogram
+	requestSize  histogram
+	responseSize histogram
+	loadTime     *time.Duration
+	buildTime    *time.Duration
+}
+
+func (f *functionMetrics) copy() *functionMetrics {
+	c := *f
+	c.invocations = make(map[string]uint64, len(f.invocations))
+	for status, n := range f.invocations {
+		c.invocations[status] = n
+	}
+	c.duration = f.duration.copy()
+	c.requestSize = f.requestSize.copy()
+	c.responseSize = f.responseSize.copy()
+	return &c
+}
+
+// Metrics records invocation metrics of functions and exports them in Prometheus
+// text format. Metrics are labeled with function name.
+type Metrics struct {
+	// DurationBuckets of invocation duration histogram, defaults to DefaultDurationBuckets
+	DurationBuckets []float64
+	// SizeBuckets of request and response size histograms, defaults to DefaultSizeBuckets
+	SizeBuckets []float64
+
+	lock      sync.Mutex
+	functions map[string]*functionMetrics
+}
+
+// snapshot returns a copy of metrics of all functions, so that they can be written
+// without blocking invocations
+func (m *Metrics) snapshot() map[string]*functionMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	functions := make(map[string]*functionMetrics, len(m.functions))
+	for name, f := range m.functions {
+		functions[name] = f.copy()
+	}
+	return functions
+}
+
+// function must be called with lock held
+func (m *Metrics) function(name string) *functionMetrics {
+	if m.functions == nil {
+		m.functions = make(map[string]*functionMetrics)
+	}
+	f, ok := m.functions[name]
+	if !ok {
+		durationBuckets := m.DurationBuckets
+		if len(durationBuckets) == 0 {
+			durationBuckets = DefaultDurationBuckets
+		}
+		sizeBuckets := m.SizeBuckets
+		if len(sizeBuckets) == 0 {
+			sizeBuckets = DefaultSizeBuckets
+		}
+		f = &functionMetrics{
+			invocations:  make(map[string]uint64),
+			duration:     newHistogram(durationBuckets),
+			requestSize:  newHistogram(sizeBuckets),
+			responseSize: newHistogram(sizeBuckets),
+		}
+		m.functions[name] = f
+	}
+	return f
+}
+
+func (m *Metrics) invocationStarted(name string, requestSize int) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	f := m.function(name)
+	f.inFlight++
+	f.requestSize.observe(float64(requestSize))
+	m.lock.Unlock()
+}
+
+func (m *Metrics) invocationFinished(name, status string, duration time.Duration, responseSize int, panicked bool) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	f := m.function(name)
+	f.inFlight--
+	f.invocations[status]++
+	if panicked {
+		f.panics++
+	}
+	f.duration.observe(duration.Seconds())
+	f.responseSize.observe(float64(responseSize))
+	m.lock.Unlock()
+}
+
+func (m *Metrics) functionLoaded(name string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	m.function(name).loadTime = &d
+	m.lock.Unlock()
+}
+
+func (m *Metrics) functionBuilt(name string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	m.function(name).buildTime = &d
+	m.lock.Unlock()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type metricsWriter struct {
+	*bufio.Writer
+	names []string
+	m     map[string]*functionMetrics
+}
+
+func (w metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w metricsWriter) sample(name string, value string, labels ...string) {
+	w.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			w.WriteByte('{')
+		} else {
+			w.WriteByte(',')
+		}
+		fmt.Fprintf(w, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+	}
+	if len(labels) > 0 {
+		w.WriteByte('}')
+	}
+	fmt.Fprintf(w, " %s\n", value)
+}
+
+func (w metricsWriter) histogram(name, help string, get func(*functionMetrics) histogram) {
+	w.header(name, "histogram", help)
+	for _, fn := range w.names {
+		h := get(w.m[fn])
+		for i, b := range h.buckets {
+			w.sample(name+"_bucket", strconv.FormatUint(h.counts[i], 10), "function", fn, "le", formatFloat(b))
+		}
+		w.sample(name+"_bucket", strconv.FormatUint(h.count, 10), "function", fn, "le", "+Inf")
+		w.sample(name+"_sum", formatFloat(h.sum), "function", fn)
+		w.sample(name+"_count", strconv.FormatUint(h.count, 10), "function", fn)
+	}
+}
+
+func (w metricsWriter) gauge(name, help string, get func(*functionMetrics) *time.Duration) {
+	w.header(name, "gauge", help)
+	for _, fn := range w.names {
+		if d := get(w.m[fn]); d != nil {
+			w.sample(name, formatFloat(d.Seconds()), "function", fn)
+		}
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes metrics in Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	functions := m.snapshot()
+	cw := &countingWriter{w: w}
+	mw := metricsWriter{
+		Writer: bufio.NewWriter(cw),
+		m:      functions,
+	}
+	for name := range functions {
+		mw.names = append(mw.names, name)
+	}
+	sort.Strings(mw.names)
+	mw.header("function_invocations_total", "counter", "Number of finished invocations by status.")
+	for _, fn := range mw.names {
+		statuses := make([]string, 0, len(functions[fn].invocations))
+		for status := range functions[fn].invocations {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			mw.sample("function_invocations_total", strconv.FormatUint(functions[fn].invocations[status], 10), "function", fn, "status", status)
+		}
+	}
+	mw.header("function_invocation_panics_total", "counter", "Number of invocations that panicked.")
+	for _, fn := range mw.names {
+		mw.sample("function_invocation_panics_total", strconv.FormatUint(functions[fn].panics, 10), "function", fn)
+	}
+	mw.header("function_invocations_in_flight", "gauge", "Number of running invocations.")
+	for _, fn := range mw.names {
+		mw.sample("function_invocations_in_flight", strconv.Itoa(functions[fn].inFlight), "function", fn)
+	}
+	mw.histogram("function_invocation_duration_seconds", "Duration of invocations.", func(f *functionMetrics) histogram { return f.duration })
+	mw.histogram("function_invocation_request_bytes", "Size of invocation input data.", func(f *functionMetrics) histogram { return f.requestSize })
+	mw.histogram("function_invocation_response_bytes", "Size of invocation output data and return value.", func(f *functionMetrics) histogram { return f.responseSize })
+	mw.gauge("function_load_duration_seconds", "Duration of the last function load.", func(f *functionMetrics) *time.Duration { return f.loadTime })
+	mw.gauge("function_build_duration_seconds", "Duration of the last function type load, which includes build of function plugin.", func(f *functionMetrics) *time.Duration { return f.buildTime })
+	err := mw.Flush()
+	return cw.n, err
+}
+
+// WriteFile writes metrics in Prometheus text format to file. File is replaced atomically,
+// so that it can be read by other processes at any time.
+func (m *Metrics) WriteFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = m.WriteTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// ServeHTTP writes metrics in Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
@@ -0,0 +1,139 @@
+package worker_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRecordsInvocations(t *testing.T) {
+	metrics := &worker.Metrics{
+		DurationBuckets: []float64{60},
+		SizeBuckets:     []float64{4, 1024},
+	}
+	ch, _ := loadTestChannelFunction(t, reflect.TypeOf((*MockTracedFuncForChannel)(nil)).Elem())
+	ch.SetMetrics(metrics)
+	ch.FunctionLoadRequest("mockRequestID", &rpc.FunctionLoadRequest{
+		FunctionId: "mockFunctionID",
+		Metadata: &rpc.RpcFunctionMetadata{
+			Name: "func",
+			Bindings: map[string]*rpc.BindingInfo{
+				"trigger": &rpc.BindingInfo{
+					Type:      "httpTrigger",
+					Direction: rpc.BindingInfo_in,
+				},
+			},
+		},
+	})
+	ch.InvocationRequest("mockRequestID", &rpc.InvocationRequest{
+		FunctionId:   "mockFunctionID",
+		InvocationId: "mockInvocationID",
+		InputData: []*rpc.ParameterBinding{
+			&rpc.ParameterBinding{
+				Name: "trigger",
+				Data: &rpc.TypedData{
+					Data: &rpc.TypedData_Http{
+						Http: &rpc.RpcHttp{
+							Body: &rpc.TypedData{
+								Data: &rpc.TypedData_String_{
+									String_: "payload",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	<-tracedFuncContext
+	ch, _ = loadTestChannelFunction(t, reflect.TypeOf((*MockPanicFunc)(nil)).Elem())
+	ch.SetMetrics(metrics)
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	var buf bytes.Buffer
+	_, err := metrics.WriteTo(&buf)
+	assert.NoError(t, err)
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE function_invocations_total counter\n",
+		`function_invocations_total{function="func",status="failure"} 1` + "\n",
+		`function_invocations_total{function="func",status="success"} 1` + "\n",
+		`function_invocation_panics_total{function="func"} 1` + "\n",
+		`function_invocations_in_flight{function="func"} 0` + "\n",
+		"# TYPE function_invocation_duration_seconds histogram\n",
+		`function_invocation_duration_seconds_bucket{function="func",le="60"} 2` + "\n",
+		`function_invocation_duration_seconds_bucket{function="func",le="+Inf"} 2` + "\n",
+		`function_invocation_duration_seconds_count{function="func"} 2` + "\n",
+		`function_invocation_request_bytes_bucket{function="func",le="4"} 1` + "\n",
+		`function_invocation_request_bytes_bucket{function="func",le="1024"} 2` + "\n",
+		`function_invocation_request_bytes_sum{function="func"} 7` + "\n",
+		`function_load_duration_seconds{function="func"} `,
+		`function_build_duration_seconds{function="func"} `,
+	} {
+		assert.Contains(t, out, line)
+	}
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", rec.Header().Get("Content-Type"))
+	assert.Equal(t, out, rec.Body.String())
+	dir, err := ioutil.TempDir("", "metrics")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.prom")
+	assert.NoError(t, metrics.WriteFile(path))
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, out, string(b))
+}
+
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	b.writing <- struct{}{}
+	<-b.release
+	return len(p), nil
+}
+
+func TestMetricsWriteDoesNotBlockInvocations(t *testing.T) {
+	metrics := &worker.Metrics{}
+	ch, _ := loadTestChannelFunction(t, reflect.TypeOf((*MockOriginalFuncForChannel)(nil)).Elem())
+	ch.SetMetrics(metrics)
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	w := &blockingWriter{
+		writing: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	written := make(chan struct{})
+	go func() {
+		metrics.WriteTo(w)
+		close(written)
+	}()
+	<-w.writing
+	invoked := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(invoked)
+	}()
+	select {
+	case <-invoked:
+	case <-time.After(time.Second * 5):
+		t.Fatal("invocation waited for metrics writer")
+	}
+	close(w.release)
+	<-written
+}
@@ -0,0 +1,33 @@
+// +build !windows
+
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// dumpMetricsOnSignal writes metrics to path each time process receives SIGUSR1
+func dumpMetricsOnSignal(metrics *Metrics, path string) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := metrics.WriteFile(path); err != nil {
+					fmt.Printf("could not write metrics: %v\n", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
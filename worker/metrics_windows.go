@@ -0,0 +1,6 @@
+package worker
+
+// dumpMetricsOnSignal is a noop on windows, metrics are written only on exit
+func dumpMetricsOnSignal(metrics *Metrics, path string) (stop func()) {
+	return func() {}
+}
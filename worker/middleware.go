@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/function"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+)
+
+// Invocation is a single function call passed through invocation middleware
+type Invocation struct {
+	// Info of invoked function
+	Info FunctionInfo
+	// Request sent by host
+	Request *rpc.InvocationRequest
+	// Object is a function instance. Trigger and input bindings are set on object
+	// when invocation handler calls function.
+	Object function.Object
+	// Logger of invocation
+	Logger api.Logger
+	// Response is sent to host after invocation handler returns. Output data and return
+	// value are set by invocation handler after function returns, result is set by worker
+	// from the error returned by invocation handler.
+	Response *rpc.InvocationResponse
+}
+
+// InvocationHandler calls function and sets invocation response
+type InvocationHandler func(ctx context.Context, inv *Invocation) error
+
+// Middleware wraps invocation handler. Middleware can modify invocation before calling next
+// handler, inspect or modify response and error returned by next or respond without calling
+// next at all.
+//
+//  func Auth(next worker.InvocationHandler) worker.InvocationHandler {
+//  	return func(ctx context.Context, inv *worker.Invocation) error {
+//  		if !authorized(inv.Request) {
+//  			return errors.New("unauthorized")
+//  		}
+//  		return next(ctx, inv)
+//  	}
+//  }
+type Middleware func(next InvocationHandler) InvocationHandler
+
+// chain wraps handler with middleware, the first middleware is the outermost one
+func chain(handler InvocationHandler, middleware ...[]Middleware) InvocationHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		for j := len(middleware[i]) - 1; j >= 0; j-- {
+			handler = middleware[i][j](handler)
+		}
+	}
+	return handler
+}
+
+// handleInvocation runs handler and converts panics in middleware to function.PanicError,
+// so that they are reported in the same way as panics in functions
+func handleInvocation(ctx context.Context, handler InvocationHandler, inv *Invocation) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = function.NewPanicError(r)
+		}
+	}()
+	return handler(ctx, inv)
+}
@@ -0,0 +1,114 @@
+package worker_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func recordingMiddleware(name string, calls *[]string) worker.Middleware {
+	return func(next worker.InvocationHandler) worker.InvocationHandler {
+		return func(ctx context.Context, inv *worker.Invocation) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, inv)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func invocationResponse(mockSender *mock.Mock) *rpc.InvocationResponse {
+	for i := len(mockSender.Calls) - 1; i >= 0; i-- {
+		msg := mockSender.Calls[i].Arguments.Get(0).(*rpc.StreamingMessage)
+		if resp, ok := msg.Content.(*rpc.StreamingMessage_InvocationResponse); ok {
+			return resp.InvocationResponse
+		}
+	}
+	return nil
+}
+
+func TestInvocationMiddleware(t *testing.T) {
+	var calls []string
+	ch, mockSender := loadTestChannelFunction(t, reflect.TypeOf((*MockOriginalFuncForChannel)(nil)).Elem())
+	ch.SetMiddleware([]worker.Middleware{
+		recordingMiddleware("first", &calls),
+		recordingMiddleware("second", &calls),
+		func(next worker.InvocationHandler) worker.InvocationHandler {
+			return func(ctx context.Context, inv *worker.Invocation) error {
+				assert.Equal(t, "func", inv.Info.Name)
+				assert.Equal(t, mockHTTPInvocationRequest, inv.Request)
+				assert.NotNil(t, inv.Logger)
+				err := next(ctx, inv)
+				assert.NotNil(t, inv.Response.ReturnValue)
+				inv.Response.ReturnValue = &rpc.TypedData{
+					Data: &rpc.TypedData_String_{String_: "modified"},
+				}
+				return err
+			}
+		},
+	}, map[string][]worker.Middleware{
+		"func":  []worker.Middleware{recordingMiddleware("function", &calls)},
+		"other": []worker.Middleware{recordingMiddleware("other", &calls)},
+	})
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	assert.Equal(t, []string{
+		"first before",
+		"second before",
+		"function before",
+		"function after",
+		"second after",
+		"first after",
+	}, calls)
+	resp := invocationResponse(&mockSender.Mock)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, rpc.StatusResult_Success, resp.Result.Status)
+		assert.Equal(t, &rpc.TypedData_String_{String_: "modified"}, resp.ReturnValue.Data)
+	}
+}
+
+func TestInvocationMiddlewareErrors(t *testing.T) {
+	called := false
+	ch, mockSender := loadTestChannelFunction(t, reflect.TypeOf((*MockOriginalFuncForChannel)(nil)).Elem())
+	ch.SetMiddleware([]worker.Middleware{
+		func(next worker.InvocationHandler) worker.InvocationHandler {
+			return func(ctx context.Context, inv *worker.Invocation) error {
+				return errors.New("unauthorized")
+			}
+		},
+		func(next worker.InvocationHandler) worker.InvocationHandler {
+			called = true
+			return next
+		},
+	}, nil)
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	resp := invocationResponse(&mockSender.Mock)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, rpc.StatusResult_Failure, resp.Result.Status)
+		assert.Equal(t, "unauthorized", resp.Result.Exception.Message)
+		assert.Nil(t, resp.ReturnValue)
+	}
+	assert.True(t, called, "middleware is built even if it is not reached")
+	ch.SetMiddleware([]worker.Middleware{
+		func(next worker.InvocationHandler) worker.InvocationHandler {
+			return func(ctx context.Context, inv *worker.Invocation) error {
+				panic("middleware failed")
+			}
+		},
+	}, nil)
+	health := &worker.Health{}
+	ch.SetHealth(health)
+	ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+	resp = invocationResponse(&mockSender.Mock)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, rpc.StatusResult_Failure, resp.Result.Status)
+		assert.Equal(t, "panic: middleware failed", resp.Result.Exception.Message)
+		assert.Contains(t, resp.Result.Exception.StackTrace, "worker_test.TestInvocationMiddlewareErrors")
+	}
+	assert.Equal(t, 1, health.Status().Panics)
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Default values used by ReconnectPolicy for unset fields
+const (
+	DefaultReconnectInitialBackoff = time.Millisecond * 100
+	DefaultReconnectMaxBackoff     = time.Second * 30
+	DefaultReconnectMultiplier     = 2.0
+)
+
+// ReconnectPolicy configures how default grpc event stream reconnects to host after
+// the connection is broken. Stream is not reconnected when host ends it gracefully
+// or when it is stopped by worker.
+//
+// Messages passed to Send while stream is reconnecting are held until connection is restored
+// and are sent in order after StartStream is sent again. If reconnect fails, all held messages
+// are dropped and the stream is closed.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of reconnect attempts before giving up. Reconnect is
+	// disabled if MaxAttempts is 0 and the number of attempts is not limited if it is negative.
+	MaxAttempts int
+	// InitialBackoff is the time to wait before first reconnect attempt.
+	// Defaults to DefaultReconnectInitialBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the time between consecutive attempts. Defaults to DefaultReconnectMaxBackoff.
+	MaxBackoff time.Duration
+	// Multiplier by which backoff grows after each failed attempt. Defaults to DefaultReconnectMultiplier.
+	Multiplier float64
+}
+
+func (r ReconnectPolicy) withEventStream(s *eventStream) {
+	s.reconnect = r
+}
+
+func (r ReconnectPolicy) enabled() bool {
+	return r.MaxAttempts != 0
+}
+
+// canRetry returns true if attempt (starting at 0) is allowed by policy
+func (r ReconnectPolicy) canRetry(attempt int) bool {
+	return r.MaxAttempts < 0 || attempt < r.MaxAttempts
+}
+
+// backoff returns the time to wait before attempt (starting at 0)
+func (r ReconnectPolicy) backoff(attempt int) time.Duration {
+	initial, max, multiplier := r.InitialBackoff, r.MaxBackoff, r.Multiplier
+	if initial <= 0 {
+		initial = DefaultReconnectInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultReconnectMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = DefaultReconnectMultiplier
+	}
+	backoff := float64(initial)
+	for i := 0; i < attempt && backoff < float64(max); i++ {
+		backoff *= multiplier
+	}
+	if backoff > float64(max) {
+		return max
+	}
+	return time.Duration(backoff)
+}
+
+// ConnectionState of default grpc event stream
+type ConnectionState int
+
+// Connection states reported by event stream
+const (
+	// Connected is reported when stream is connected to host for the first time
+	Connected ConnectionState = iota
+	// Reconnecting is reported when connection is broken and stream attempts to reconnect
+	Reconnecting
+	// Reconnected is reported when connection is restored
+	Reconnected
+	// Disconnected is reported when stream gives up on reconnecting or connection is broken
+	// and reconnect is disabled
+	Disconnected
+)
+
+func (c ConnectionState) String() string {
+	switch c {
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	case Reconnected:
+		return "reconnected"
+	case Disconnected:
+		return "disconnected"
+	}
+	return fmt.Sprintf("ConnectionState(%d)", int(c))
+}
+
+// ConnectionStateEventStreamOption sets a callback called by default grpc event stream on
+// connection state change. Error is the cause of the state change, if any.
+//
+// Callback is called synchronously and must not call Send on the stream.
+type ConnectionStateEventStreamOption func(state ConnectionState, err error)
+
+func (c ConnectionStateEventStreamOption) withEventStream(s *eventStream) {
+	s.onStateChange = c
+}
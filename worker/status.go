@@ -0,0 +1,197 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultStuckInvocationThreshold is a default time after which running invocation is reported as stuck
+const DefaultStuckInvocationThreshold = time.Minute * 5
+
+// StuckInvocation is an invocation running longer than the threshold configured in Health
+type StuckInvocation struct {
+	InvocationID string
+	FunctionID   string
+	Started      time.Time
+	Running      time.Duration
+}
+
+// Status is a snapshot of worker health
+type Status struct {
+	InFlight             int
+	QueueDepth           int
+	LastSuccess          time.Time
+	LastSuccessFunction  string
+	StuckInvocations     []StuckInvocation
+	StuckInvocationAfter time.Duration
+	Panics               int
+	RestartRequired      bool
+	// DroppedLogs is the number of logs dropped because log queue was full
+	DroppedLogs int
+}
+
+// Healthy returns false if worker has stuck invocations or requires restart
+func (s Status) Healthy() bool {
+	return len(s.StuckInvocations) == 0 && !s.RestartRequired
+}
+
+type runningInvocation struct {
+	functionID string
+	started    time.Time
+}
+
+// Health tracks state of invocations in worker
+type Health struct {
+	// StuckAfter is the time after which running invocation is considered stuck.
+	// Defaults to DefaultStuckInvocationThreshold.
+	StuckAfter time.Duration
+	// MaxPanics is the number of panics in user functions after which worker restarts.
+	// Restart on panics is disabled if not set.
+	MaxPanics int
+	// PanicWindow limits counting panics to those that happened in the given period.
+	// All panics since worker start are counted if not set.
+	PanicWindow time.Duration
+
+	lock                sync.Mutex
+	queued              int
+	running             map[string]runningInvocation
+	lastSuccess         time.Time
+	lastSuccessFunction string
+	panics              int
+	recentPanics        []time.Time
+	restart             chan struct{}
+	restartRequired     bool
+	droppedLogs         int
+}
+
+func (h *Health) invocationQueued() {
+	h.lock.Lock()
+	h.queued++
+	h.lock.Unlock()
+}
+
+func (h *Health) invocationDequeued() {
+	h.lock.Lock()
+	h.queued--
+	h.lock.Unlock()
+}
+
+func (h *Health) invocationStarted(invocationID, functionID string) {
+	h.lock.Lock()
+	if h.running == nil {
+		h.running = make(map[string]runningInvocation)
+	}
+	h.running[invocationID] = runningInvocation{
+		functionID: functionID,
+		started:    time.Now(),
+	}
+	h.lock.Unlock()
+}
+
+func (h *Health) invocationFinished(invocationID string, success bool) {
+	h.lock.Lock()
+	if inv, ok := h.running[invocationID]; ok {
+		delete(h.running, invocationID)
+		if success {
+			h.lastSuccess = time.Now()
+			h.lastSuccessFunction = inv.functionID
+		}
+	}
+	h.lock.Unlock()
+}
+
+func (h *Health) logDropped() {
+	h.lock.Lock()
+	h.droppedLogs++
+	h.lock.Unlock()
+}
+
+// invocationPanicked records a panic and returns true if it caused panic limit to be reached
+func (h *Health) invocationPanicked() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.panics++
+	if h.MaxPanics <= 0 || h.restartRequired {
+		return false
+	}
+	now := time.Now()
+	recent := h.recentPanics[:0]
+	for _, t := range h.recentPanics {
+		if h.PanicWindow <= 0 || now.Sub(t) < h.PanicWindow {
+			recent = append(recent, t)
+		}
+	}
+	h.recentPanics = append(recent, now)
+	if len(h.recentPanics) < h.MaxPanics {
+		return false
+	}
+	h.restartRequired = true
+	close(h.restartChan())
+	return true
+}
+
+// restartChan must be called with lock held
+func (h *Health) restartChan() chan struct{} {
+	if h.restart == nil {
+		h.restart = make(chan struct{})
+	}
+	return h.restart
+}
+
+// restartRequested is closed when worker should restart
+func (h *Health) restartRequested() <-chan struct{} {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.restartChan()
+}
+
+func (h *Health) stuckAfter() time.Duration {
+	if h.StuckAfter <= 0 {
+		return DefaultStuckInvocationThreshold
+	}
+	return h.StuckAfter
+}
+
+// Status returns current worker status
+func (h *Health) Status() Status {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	now := time.Now()
+	status := Status{
+		InFlight:             len(h.running),
+		QueueDepth:           h.queued,
+		LastSuccess:          h.lastSuccess,
+		LastSuccessFunction:  h.lastSuccessFunction,
+		StuckInvocationAfter: h.stuckAfter(),
+		Panics:               h.panics,
+		RestartRequired:      h.restartRequired,
+		DroppedLogs:          h.droppedLogs,
+	}
+	for id, inv := range h.running {
+		if running := now.Sub(inv.started); running >= status.StuckInvocationAfter {
+			status.StuckInvocations = append(status.StuckInvocations, StuckInvocation{
+				InvocationID: id,
+				FunctionID:   inv.functionID,
+				Started:      inv.started,
+				Running:      running,
+			})
+		}
+	}
+	sort.Slice(status.StuckInvocations, func(i, j int) bool {
+		return status.StuckInvocations[i].Started.Before(status.StuckInvocations[j].Started)
+	})
+	return status
+}
+
+// ServeHTTP writes worker status as JSON. Responds with 503 if worker is not healthy.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := h.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,76 @@
+package worker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/graphql-editor/azure-functions-golang-worker/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHealthTracksInvocations(t *testing.T) {
+	health := &worker.Health{
+		StuckAfter: time.Millisecond,
+	}
+	ch, mockSender := loadTestChannelFunction(t, reflect.TypeOf((*MockBlockingFuncForChannel)(nil)).Elem())
+	ch.SetHealth(health)
+	done := make(chan struct{})
+	go func() {
+		ch.InvocationRequest("mockRequestID", mockHTTPInvocationRequest)
+		close(done)
+	}()
+	<-blockingFuncStarted
+	time.Sleep(time.Millisecond * 5)
+	status := health.Status()
+	assert.Equal(t, 1, status.InFlight)
+	assert.False(t, status.Healthy())
+	if assert.Len(t, status.StuckInvocations, 1) {
+		assert.Equal(t, "mockInvocationID", status.StuckInvocations[0].InvocationID)
+		assert.Equal(t, "mockFunctionID", status.StuckInvocations[0].FunctionID)
+	}
+	rec := httptest.NewRecorder()
+	health.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	ch.StatusRequest("mockStatusRequestID", &rpc.WorkerStatusRequest{})
+	mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+		RequestId: "mockStatusRequestID",
+		Content: &rpc.StreamingMessage_WorkerStatusResponse{
+			WorkerStatusResponse: &rpc.WorkerStatusResponse{},
+		},
+	})
+	mockSender.AssertCalled(t, "Send", mock.MatchedBy(func(v interface{}) bool {
+		msg, ok := v.(*rpc.StreamingMessage).Content.(*rpc.StreamingMessage_RpcLog)
+		return ok && msg.RpcLog.Level == rpc.RpcLog_Warning
+	}))
+	ch.InvocationCancel("mockCancelRequestID", &rpc.InvocationCancel{
+		InvocationId: "mockInvocationID",
+	})
+	<-done
+	status = health.Status()
+	assert.Equal(t, 0, status.InFlight)
+	assert.True(t, status.Healthy())
+	assert.True(t, status.LastSuccess.IsZero())
+	rec = httptest.NewRecorder()
+	health.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/status", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHeartbeat(t *testing.T) {
+	var mockSender mocks.Sender
+	mockSender.On("Send", mock.Anything)
+	ch := worker.NewChannel()
+	ch.SetEventStream(&mockSender)
+	ch.Heartbeat("mockRequestID", &rpc.WorkerHeartbeat{})
+	mockSender.AssertCalled(t, "Send", &rpc.StreamingMessage{
+		RequestId: "mockRequestID",
+		Content: &rpc.StreamingMessage_WorkerHeartbeat{
+			WorkerHeartbeat: &rpc.WorkerHeartbeat{},
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// parseTimeSpan parses timeout in .NET TimeSpan format ([d.]hh:mm[:ss[.fffffff]]) used by host.json.
+// Value -1 means no timeout and is returned as 0.
+func parseTimeSpan(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-1" {
+		return 0, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.Errorf("invalid time span %s", s)
+	}
+	var days int64
+	hours := parts[0]
+	if i := strings.Index(hours, "."); i != -1 {
+		d, err := strconv.ParseInt(hours[:i], 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid time span %s", s)
+		}
+		days, hours = d, hours[i+1:]
+	}
+	h, err := strconv.ParseInt(hours, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid time span %s", s)
+	}
+	m, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid time span %s", s)
+	}
+	var sec float64
+	if len(parts) == 3 {
+		sec, err = strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid time span %s", s)
+		}
+	}
+	d := time.Duration(days)*time.Hour*24 +
+		time.Duration(h)*time.Hour +
+		time.Duration(m)*time.Minute +
+		time.Duration(sec*float64(time.Second))
+	if d < 0 {
+		return 0, errors.Errorf("invalid time span %s", s)
+	}
+	return d, nil
+}
+
+type timeoutConfig struct {
+	FunctionTimeout string `json:"functionTimeout"`
+}
+
+// readTimeout reads functionTimeout property from json file. Returns false if
+// file does not exist or does not define timeout.
+func readTimeout(path string) (time.Duration, bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var cfg timeoutConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return 0, false, errors.Wrapf(err, "could not read %s", path)
+	}
+	if cfg.FunctionTimeout == "" {
+		return 0, false, nil
+	}
+	timeout, err := parseTimeSpan(cfg.FunctionTimeout)
+	return timeout, true, err
+}
+
+// functionTimeout returns timeout defined by functionTimeout property of function.json in function
+// directory or, if it's not defined there, of host.json in function app directory.
+func functionTimeout(directory string) (time.Duration, error) {
+	if directory == "" {
+		return 0, nil
+	}
+	timeout, ok, err := readTimeout(filepath.Join(directory, "function.json"))
+	if ok || err != nil {
+		return timeout, err
+	}
+	timeout, _, err = readTimeout(filepath.Join(filepath.Dir(directory), "host.json"))
+	return timeout, err
+}
+
+// goroutineDump returns stack traces of all goroutines
+func goroutineDump() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
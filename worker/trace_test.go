@@ -0,0 +1,51 @@
+package worker_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+var tracedFuncContext = make(chan api.TraceContext, 1)
+
+type MockTracedFuncForChannel map[string]interface{}
+
+func (m MockTracedFuncForChannel) Run(ctx context.Context, logger api.Logger) {
+	tc, _ := api.GetTraceContext(ctx)
+	tracedFuncContext <- tc
+}
+
+func TestInvocationTraceContext(t *testing.T) {
+	ch, _ := loadTestChannelFunction(t, reflect.TypeOf((*MockTracedFuncForChannel)(nil)).Elem())
+	ch.InvocationRequest("mockRequestID", &rpc.InvocationRequest{
+		FunctionId:   "mockFunctionID",
+		InvocationId: "mockInvocationID",
+		InputData:    mockHTTPInvocationRequest.InputData,
+		TraceContext: &rpc.RpcTraceContext{
+			TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			TraceState:  "congo=t61rcWkgMzE",
+			Attributes: map[string]string{
+				"ProcessId": "1",
+			},
+		},
+	})
+	tc := <-tracedFuncContext
+	assert.True(t, tc.IsValid())
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", tc.TraceParent())
+	assert.Equal(t, "congo=t61rcWkgMzE", tc.TraceState)
+	assert.Equal(t, map[string]string{"ProcessId": "1"}, tc.Attributes)
+	ch.InvocationRequest("mockRequestID", &rpc.InvocationRequest{
+		FunctionId:   "mockFunctionID",
+		InvocationId: "mockInvocationID",
+		InputData:    mockHTTPInvocationRequest.InputData,
+		TraceContext: &rpc.RpcTraceContext{
+			TraceParent: "|legacy-activity-id.1.",
+		},
+	})
+	tc = <-tracedFuncContext
+	assert.False(t, tc.IsValid())
+}
@@ -1,7 +1,13 @@
 package worker
 
 import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 )
 
@@ -22,6 +28,10 @@ type EventStream interface {
 type Channel interface {
 	SetEventStream(Sender)
 	SetLoader(Loader)
+	SetHealth(*Health)
+	SetMetrics(*Metrics)
+	SetMiddleware(middleware []Middleware, functionMiddleware map[string][]Middleware)
+	SetHostInfo(*HostInfo)
 	StartStream(requestID string, msg *rpc.StartStream)
 	InitRequest(requestID string, msg *rpc.WorkerInitRequest)
 	Heartbeat(requestID string, msg *rpc.WorkerHeartbeat)
@@ -34,6 +44,14 @@ type Channel interface {
 	FunctionEnvironmentReloadRequest(requestID string, msg *rpc.FunctionEnvironmentReloadRequest)
 }
 
+// TerminateCancelTimeout is the time worker waits for invocations to return after their
+// context was cancelled due to worker termination
+const TerminateCancelTimeout = time.Second
+
+// RestartGracePeriod is the time worker waits for running invocations before restarting
+// after panic limit configured in Health was reached
+const RestartGracePeriod = time.Second * 5
+
 // Worker handles incoming messages from event stream
 type Worker struct {
 	Host      string
@@ -43,6 +61,36 @@ type Worker struct {
 	Stream    EventStream
 	Channel   Channel
 	Loader    Loader
+	// MaxConcurrency limits number of invocations running at the same time.
+	// Defaults to DefaultMaxConcurrency if not set.
+	MaxConcurrency int
+	// MaxFunctionConcurrency limits number of invocations of a single function
+	// running at the same time. Defaults to MaxConcurrency if not set.
+	MaxFunctionConcurrency int
+	// Health tracks worker status. Created by Listen if not set.
+	Health *Health
+	// Middleware wraps invocations of all functions. The first middleware is the outermost one.
+	Middleware []Middleware
+	// FunctionMiddleware maps function name to middleware wrapping invocations of that
+	// function. Function middleware is called inside of Middleware.
+	FunctionMiddleware map[string][]Middleware
+	// Metrics records invocation metrics. Created by Listen if not set.
+	Metrics *Metrics
+	// MetricsFile is an optional path to which metrics are written in Prometheus text
+	// format when worker exits and, on systems other than windows, when worker receives SIGUSR1
+	MetricsFile string
+	// HostInfo holds host information and capabilities negotiated with host.
+	// Created by Listen if not set.
+	HostInfo *HostInfo
+	// LogQueueSize is the number of logs waiting to be sent to host. Logs are sent
+	// asynchronously, defaults to DefaultLogQueueSize if not set.
+	LogQueueSize int
+	// LogQueuePolicy decides if logging blocks or drops logs when log queue is full.
+	// Dropped logs are counted in Health status.
+	LogQueuePolicy LogQueuePolicy
+	// DebugAddress is an optional local address on which worker serves
+	// its status at /debug/status and metrics in Prometheus text format at /metrics
+	DebugAddress string
 }
 
 func (w *Worker) checkConfig() bool {
@@ -65,18 +113,37 @@ func (w *Worker) eventStream() (stream EventStream, err error) {
 	return
 }
 
-func (w *Worker) getChannel(stream EventStream) Channel {
+func (w *Worker) getChannel(stream Sender) Channel {
 	ch := w.Channel
 	if ch == nil {
 		ch = NewChannel()
 	}
 	ch.SetEventStream(stream)
 	ch.SetLoader(w.Loader)
+	ch.SetHealth(w.Health)
+	ch.SetMetrics(w.Metrics)
+	ch.SetMiddleware(w.Middleware, w.FunctionMiddleware)
+	ch.SetHostInfo(w.HostInfo)
 	return ch
 }
 
 // Listen handles incoming and outgoing streaming messages from event stream
 //
+// Control messages are handled by channel one at a time in the order they were received.
+// Invocations are dispatched to a bounded pool and do not block the receive loop, which means
+// that the order of invocation responses is not guaranteed to match the order of requests.
+// Logs are queued and sent asynchronously in batches with lower priority than other messages,
+// but logs of an invocation are always sent before it's response. Listen returns only after
+// all dispatched invocations have finished.
+//
+// On WorkerTerminate worker stops accepting new messages and waits for running invocations
+// for the duration of grace period. Invocations still running after grace period are cancelled.
+// Functions being reloaded are awaited, loaded functions are closed, loader is closed, if it implements
+// io.Closer, and pending logs and messages are flushed before Listen returns.
+//
+// If panic limit configured in Health is reached, worker drains invocations in the same way and returns
+// an error, so that the process can exit and be restarted by host.
+//
 // It is not safe to call listen concurrently
 func (w *Worker) Listen() (err error) {
 	if w.WorkerID == "" || w.RequestID == "" {
@@ -87,7 +154,37 @@ func (w *Worker) Listen() (err error) {
 		if err = stream.Start(); err != nil {
 			return
 		}
-		defer stream.Stop()
+		if w.Health == nil {
+			w.Health = &Health{}
+		}
+		if w.HostInfo == nil {
+			w.HostInfo = &HostInfo{}
+		}
+		if w.Metrics == nil {
+			w.Metrics = &Metrics{}
+		}
+		if w.DebugAddress != "" {
+			debug := newDebugServer(w.DebugAddress, w.Health, w.Metrics)
+			debug.Start()
+			defer debug.Stop()
+		}
+		if w.MetricsFile != "" {
+			stopDump := dumpMetricsOnSignal(w.Metrics, w.MetricsFile)
+			defer func() {
+				stopDump()
+				w.writeMetricsFile()
+			}()
+		}
+		logs := newLogQueue(stream, w.LogQueueSize, w.LogQueuePolicy, w.Health)
+		ch := w.getChannel(logs)
+		defer func() {
+			// functions are closed first, so that reloads still running can send their responses
+			if closeErr := w.closeLoader(ch); err == nil {
+				err = closeErr
+			}
+			logs.Close()
+			stream.Stop()
+		}()
 		stream.Send(&rpc.StreamingMessage{
 			Content: &rpc.StreamingMessage_StartStream{
 				StartStream: &rpc.StartStream{
@@ -95,33 +192,115 @@ func (w *Worker) Listen() (err error) {
 				},
 			},
 		})
-		ch := w.getChannel(stream)
+		dispatcher := newDispatcher(w.MaxConcurrency, w.MaxFunctionConcurrency, w.Health)
+		done := make(chan struct{})
+		defer close(done)
+		messages := receive(stream, done)
+		var terminate *rpc.WorkerTerminate
+		for ok := true; ok && terminate == nil; {
+			var msg *rpc.StreamingMessage
+			select {
+			case msg, ok = <-messages:
+				if ok {
+					terminate = w.handle(ch, dispatcher, msg)
+				}
+			case <-w.Health.restartRequested():
+				err = errors.Errorf("worker restart required after %d panics", w.Health.Status().Panics)
+				terminate = &rpc.WorkerTerminate{
+					GracePeriod: ptypes.DurationProto(RestartGracePeriod),
+				}
+				ch.Terminate("", terminate)
+			}
+		}
+		if terminate != nil {
+			w.drain(dispatcher, gracePeriod(terminate.GetGracePeriod()))
+		} else {
+			dispatcher.Wait()
+		}
+	}
+	return
+}
+
+// receive reads messages from stream until stream is closed or done is closed
+func receive(stream EventStream, done <-chan struct{}) <-chan *rpc.StreamingMessage {
+	messages := make(chan *rpc.StreamingMessage)
+	go func() {
+		defer close(messages)
 		msg, ok := stream.Recv()
 		for ok {
-			switch msgT := msg.Content.(type) {
-			case *rpc.StreamingMessage_StartStream:
-				ch.StartStream(msg.RequestId, msgT.StartStream)
-			case *rpc.StreamingMessage_WorkerInitRequest:
-				ch.InitRequest(msg.RequestId, msgT.WorkerInitRequest)
-			case *rpc.StreamingMessage_WorkerHeartbeat:
-				ch.Heartbeat(msg.RequestId, msgT.WorkerHeartbeat)
-			case *rpc.StreamingMessage_WorkerTerminate:
-				ch.Terminate(msg.RequestId, msgT.WorkerTerminate)
-			case *rpc.StreamingMessage_WorkerStatusRequest:
-				ch.StatusRequest(msg.RequestId, msgT.WorkerStatusRequest)
-			case *rpc.StreamingMessage_FileChangeEventRequest:
-				ch.FileChangeEventRequest(msg.RequestId, msgT.FileChangeEventRequest)
-			case *rpc.StreamingMessage_FunctionLoadRequest:
-				ch.FunctionLoadRequest(msg.RequestId, msgT.FunctionLoadRequest)
-			case *rpc.StreamingMessage_InvocationRequest:
-				ch.InvocationRequest(msg.RequestId, msgT.InvocationRequest)
-			case *rpc.StreamingMessage_InvocationCancel:
-				ch.InvocationCancel(msg.RequestId, msgT.InvocationCancel)
-			case *rpc.StreamingMessage_FunctionEnvironmentReloadRequest:
-				ch.FunctionEnvironmentReloadRequest(msg.RequestId, msgT.FunctionEnvironmentReloadRequest)
+			select {
+			case messages <- msg:
+			case <-done:
+				return
 			}
 			msg, ok = stream.Recv()
 		}
+	}()
+	return messages
+}
+
+// handle routes message to channel, returns terminate message if worker should stop receiving messages
+func (w *Worker) handle(ch Channel, dispatcher *dispatcher, msg *rpc.StreamingMessage) *rpc.WorkerTerminate {
+	switch msgT := msg.Content.(type) {
+	case *rpc.StreamingMessage_StartStream:
+		ch.StartStream(msg.RequestId, msgT.StartStream)
+	case *rpc.StreamingMessage_WorkerInitRequest:
+		ch.InitRequest(msg.RequestId, msgT.WorkerInitRequest)
+	case *rpc.StreamingMessage_WorkerHeartbeat:
+		ch.Heartbeat(msg.RequestId, msgT.WorkerHeartbeat)
+	case *rpc.StreamingMessage_WorkerTerminate:
+		ch.Terminate(msg.RequestId, msgT.WorkerTerminate)
+		return msgT.WorkerTerminate
+	case *rpc.StreamingMessage_WorkerStatusRequest:
+		ch.StatusRequest(msg.RequestId, msgT.WorkerStatusRequest)
+	case *rpc.StreamingMessage_FileChangeEventRequest:
+		ch.FileChangeEventRequest(msg.RequestId, msgT.FileChangeEventRequest)
+	case *rpc.StreamingMessage_FunctionLoadRequest:
+		ch.FunctionLoadRequest(msg.RequestId, msgT.FunctionLoadRequest)
+	case *rpc.StreamingMessage_InvocationRequest:
+		dispatcher.Dispatch(ch, msg.RequestId, msgT.InvocationRequest)
+	case *rpc.StreamingMessage_InvocationCancel:
+		ch.InvocationCancel(msg.RequestId, msgT.InvocationCancel)
+	case *rpc.StreamingMessage_FunctionEnvironmentReloadRequest:
+		ch.FunctionEnvironmentReloadRequest(msg.RequestId, msgT.FunctionEnvironmentReloadRequest)
+	}
+	return nil
+}
+
+func (w *Worker) drain(d *dispatcher, gracePeriod time.Duration) {
+	if d.WaitTimeout(gracePeriod) {
+		return
+	}
+	// channel cancels invocations once grace period passes, give them some time to return
+	if !d.WaitTimeout(TerminateCancelTimeout) {
+		fmt.Println("worker terminated with invocations still running")
+	}
+}
+
+// functionCloser is implemented by channels that close loaded functions themselves,
+// for instance to wait for functions reloaded in background
+type functionCloser interface {
+	CloseFunctions() error
+}
+
+// closeLoader closes loaded functions and then type loader
+func (w *Worker) closeLoader(ch Channel) error {
+	var err error
+	if closer, ok := ch.(functionCloser); ok {
+		err = closer.CloseFunctions()
+	} else {
+		err = w.Loader.CloseFunctions()
+	}
+	if closer, ok := w.Loader.TypeLoader.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = multierror.Append(err, closeErr)
+		}
+	}
+	return err
+}
+
+func (w *Worker) writeMetricsFile() {
+	if err := w.Metrics.WriteFile(w.MetricsFile); err != nil {
+		fmt.Printf("could not write metrics: %v\n", err)
 	}
-	return
 }
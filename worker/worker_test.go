@@ -1,10 +1,18 @@
 package worker_test
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/graphql-editor/azure-functions-golang-worker/api"
+	"github.com/graphql-editor/azure-functions-golang-worker/mocks"
 	"github.com/graphql-editor/azure-functions-golang-worker/rpc"
 	"github.com/graphql-editor/azure-functions-golang-worker/worker"
 	"github.com/stretchr/testify/assert"
@@ -74,6 +82,22 @@ func (m *MockChannel) SetLoader(loader worker.Loader) {
 	m.Called(loader)
 }
 
+func (m *MockChannel) SetHealth(health *worker.Health) {
+	m.Called(health)
+}
+
+func (m *MockChannel) SetMetrics(metrics *worker.Metrics) {
+	m.Called(metrics)
+}
+
+func (m *MockChannel) SetMiddleware(middleware []worker.Middleware, functionMiddleware map[string][]worker.Middleware) {
+	m.Called(middleware, functionMiddleware)
+}
+
+func (m *MockChannel) SetHostInfo(hostInfo *worker.HostInfo) {
+	m.Called(hostInfo)
+}
+
 func TestWorkerCallsChannelStartStream(t *testing.T) {
 	data := []struct {
 		function string
@@ -118,24 +142,6 @@ func TestWorkerCallsChannelStartStream(t *testing.T) {
 				},
 			},
 		},
-		{
-			function: "Terminate",
-			expected: &rpc.WorkerTerminate{
-				GracePeriod: &duration.Duration{
-					Seconds: 1,
-				},
-			},
-			msg: &rpc.StreamingMessage{
-				RequestId: "mockRequestId",
-				Content: &rpc.StreamingMessage_WorkerTerminate{
-					WorkerTerminate: &rpc.WorkerTerminate{
-						GracePeriod: &duration.Duration{
-							Seconds: 1,
-						},
-					},
-				},
-			},
-		},
 		{
 			function: "StatusRequest",
 			expected: &rpc.WorkerStatusRequest{},
@@ -220,10 +226,33 @@ func TestWorkerCallsChannelStartStream(t *testing.T) {
 				},
 			},
 		},
+		// worker stops receiving messages after terminate
+		{
+			function: "Terminate",
+			expected: &rpc.WorkerTerminate{
+				GracePeriod: &duration.Duration{
+					Seconds: 1,
+				},
+			},
+			msg: &rpc.StreamingMessage{
+				RequestId: "mockRequestId",
+				Content: &rpc.StreamingMessage_WorkerTerminate{
+					WorkerTerminate: &rpc.WorkerTerminate{
+						GracePeriod: &duration.Duration{
+							Seconds: 1,
+						},
+					},
+				},
+			},
+		},
 	}
 	var mockChannel MockChannel
 	mockChannel.On("SetEventStream", mock.Anything)
 	mockChannel.On("SetLoader", mock.Anything)
+	mockChannel.On("SetHealth", mock.Anything)
+	mockChannel.On("SetMetrics", mock.Anything)
+	mockChannel.On("SetMiddleware", mock.Anything, mock.Anything)
+	mockChannel.On("SetHostInfo", mock.Anything)
 	mockChannel.wg.Add(len(data))
 	worker := worker.Worker{
 		Channel:   &mockChannel,
@@ -247,3 +276,501 @@ func TestWorkerCallsChannelStartStream(t *testing.T) {
 		mockChannel.AssertCalled(t, tt.function, tt.msg.RequestId, mock.MatchedBy(matcher(tt.expected)))
 	}
 }
+
+func TestWorkerDoesNotBlockOnInvocation(t *testing.T) {
+	release := make(chan struct{})
+	var mockChannel MockChannel
+	mockChannel.On("SetEventStream", mock.Anything)
+	mockChannel.On("SetLoader", mock.Anything)
+	mockChannel.On("SetHealth", mock.Anything)
+	mockChannel.On("SetMetrics", mock.Anything)
+	mockChannel.On("SetMiddleware", mock.Anything, mock.Anything)
+	mockChannel.On("SetHostInfo", mock.Anything)
+	mockChannel.On("InvocationRequest", "mockInvocationRequestId", mock.Anything).Run(func(mock.Arguments) {
+		<-release
+	})
+	mockChannel.On("Heartbeat", "mockHeartbeatRequestId", mock.Anything)
+	mockChannel.wg.Add(1)
+	worker := worker.Worker{
+		Channel:   &mockChannel,
+		WorkerID:  "mockWorkerID",
+		RequestID: "mockRequestID",
+		Port:      "1234",
+	}
+	srv, eventStream := setupMockGRPCServer(&worker)
+	defer srv.Stop()
+	eventStream.Send(&rpc.StreamingMessage{
+		RequestId: "mockInvocationRequestId",
+		Content: &rpc.StreamingMessage_InvocationRequest{
+			InvocationRequest: &rpc.InvocationRequest{
+				FunctionId: "mockFunctionId",
+			},
+		},
+	})
+	eventStream.Send(&rpc.StreamingMessage{
+		RequestId: "mockHeartbeatRequestId",
+		Content: &rpc.StreamingMessage_WorkerHeartbeat{
+			WorkerHeartbeat: &rpc.WorkerHeartbeat{},
+		},
+	})
+	// heartbeat is handled while invocation is still running
+	mockChannel.wg.Wait()
+	mockChannel.wg.Add(1)
+	close(release)
+	mockChannel.wg.Wait()
+	mockChannel.AssertNumberOfCalls(t, "InvocationRequest", 1)
+	mockChannel.AssertNumberOfCalls(t, "Heartbeat", 1)
+}
+
+func TestWorkerLimitsConcurrentInvocations(t *testing.T) {
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	var mockChannel MockChannel
+	mockChannel.On("SetEventStream", mock.Anything)
+	mockChannel.On("SetLoader", mock.Anything)
+	mockChannel.On("SetHealth", mock.Anything)
+	mockChannel.On("SetMetrics", mock.Anything)
+	mockChannel.On("SetMiddleware", mock.Anything, mock.Anything)
+	mockChannel.On("SetHostInfo", mock.Anything)
+	mockChannel.On("InvocationRequest", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args.Get(0).(string)
+		<-release
+	})
+	mockChannel.wg.Add(3)
+	worker := worker.Worker{
+		Channel:                &mockChannel,
+		WorkerID:               "mockWorkerID",
+		RequestID:              "mockRequestID",
+		Port:                   "1234",
+		MaxConcurrency:         2,
+		MaxFunctionConcurrency: 1,
+	}
+	srv, eventStream := setupMockGRPCServer(&worker)
+	defer srv.Stop()
+	for _, tt := range []struct {
+		requestID  string
+		functionID string
+	}{
+		{requestID: "mockRequestId1", functionID: "mockFunctionId1"},
+		{requestID: "mockRequestId2", functionID: "mockFunctionId1"},
+		{requestID: "mockRequestId3", functionID: "mockFunctionId2"},
+	} {
+		eventStream.Send(&rpc.StreamingMessage{
+			RequestId: tt.requestID,
+			Content: &rpc.StreamingMessage_InvocationRequest{
+				InvocationRequest: &rpc.InvocationRequest{
+					FunctionId: tt.functionID,
+				},
+			},
+		})
+	}
+	// one invocation of each function can run
+	running := []string{<-started, <-started}
+	assert.Contains(t, running, "mockRequestId3")
+	select {
+	case id := <-started:
+		t.Fatalf("invocation %s started while limit was reached", id)
+	case <-time.After(time.Millisecond * 50):
+	}
+	close(release)
+	mockChannel.wg.Wait()
+	assert.Len(t, started, 1)
+	mockChannel.AssertNumberOfCalls(t, "InvocationRequest", 3)
+}
+
+type closingTypeLoader struct {
+	mocks.TypeLoader
+	closed chan struct{}
+}
+
+func (c *closingTypeLoader) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestWorkerDrainsOnTerminate(t *testing.T) {
+	var finished int32
+	release := make(chan struct{})
+	var mockChannel MockChannel
+	mockChannel.On("SetEventStream", mock.Anything)
+	mockChannel.On("SetLoader", mock.Anything)
+	mockChannel.On("SetHealth", mock.Anything)
+	mockChannel.On("SetMetrics", mock.Anything)
+	mockChannel.On("SetMiddleware", mock.Anything, mock.Anything)
+	mockChannel.On("SetHostInfo", mock.Anything)
+	mockChannel.On("InvocationRequest", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		<-release
+		atomic.StoreInt32(&finished, 1)
+	})
+	mockChannel.On("Terminate", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(release)
+	})
+	mockChannel.wg.Add(2)
+	loader := closingTypeLoader{closed: make(chan struct{})}
+	worker := worker.Worker{
+		Channel:   &mockChannel,
+		WorkerID:  "mockWorkerID",
+		RequestID: "mockRequestID",
+		Port:      "1234",
+		Loader: worker.Loader{
+			TypeLoader: &loader,
+		},
+	}
+	srv, eventStream := setupMockGRPCServer(&worker)
+	defer srv.Stop()
+	eventStream.Send(&rpc.StreamingMessage{
+		RequestId: "mockInvocationRequestId",
+		Content: &rpc.StreamingMessage_InvocationRequest{
+			InvocationRequest: &rpc.InvocationRequest{
+				FunctionId: "mockFunctionId",
+			},
+		},
+	})
+	eventStream.Send(&rpc.StreamingMessage{
+		RequestId: "mockTerminateRequestId",
+		Content: &rpc.StreamingMessage_WorkerTerminate{
+			WorkerTerminate: &rpc.WorkerTerminate{
+				GracePeriod: &duration.Duration{
+					Seconds: 5,
+				},
+			},
+		},
+	})
+	select {
+	case <-loader.closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not exit after terminate")
+	}
+	mockChannel.wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&finished))
+}
+
+type memoryEventStream struct {
+	recv chan *rpc.StreamingMessage
+	sent chan *rpc.StreamingMessage
+}
+
+func (m *memoryEventStream) Recv() (*rpc.StreamingMessage, bool) {
+	msg, ok := <-m.recv
+	return msg, ok
+}
+
+func (m *memoryEventStream) Send(msg *rpc.StreamingMessage) {
+	m.sent <- msg
+}
+
+func (m *memoryEventStream) Start() error { return nil }
+
+func (m *memoryEventStream) Stop() {}
+
+type MockPanicFunc map[string]interface{}
+
+func (m MockPanicFunc) Run(ctx context.Context, logger api.Logger) {
+	panic("function failed")
+}
+
+func TestWorkerRestartsAfterPanics(t *testing.T) {
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockPanicFunc)(nil)).Elem(), nil)
+	stream := &memoryEventStream{
+		recv: make(chan *rpc.StreamingMessage),
+		sent: make(chan *rpc.StreamingMessage, 100),
+	}
+	worker := worker.Worker{
+		WorkerID:  "mockWorkerID",
+		RequestID: "mockRequestID",
+		Stream:    stream,
+		Loader: worker.Loader{
+			TypeLoader:      &mockLoader,
+			LoadedFunctions: map[string]worker.Function{},
+		},
+		Health: &worker.Health{
+			MaxPanics: 2,
+		},
+	}
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- worker.Listen()
+	}()
+	stream.recv <- &rpc.StreamingMessage{
+		RequestId: "mockRequestId",
+		Content: &rpc.StreamingMessage_FunctionLoadRequest{
+			FunctionLoadRequest: &rpc.FunctionLoadRequest{
+				FunctionId: "mockFunctionId",
+				Metadata: &rpc.RpcFunctionMetadata{
+					Name: "func",
+					Bindings: map[string]*rpc.BindingInfo{
+						"trigger": &rpc.BindingInfo{
+							Type:      "httpTrigger",
+							Direction: rpc.BindingInfo_in,
+						},
+					},
+				},
+			},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		stream.recv <- &rpc.StreamingMessage{
+			RequestId: "mockRequestId",
+			Content: &rpc.StreamingMessage_InvocationRequest{
+				InvocationRequest: &rpc.InvocationRequest{
+					FunctionId:   "mockFunctionId",
+					InvocationId: fmt.Sprintf("mockInvocationId%d", i),
+					InputData: []*rpc.ParameterBinding{
+						&rpc.ParameterBinding{
+							Name: "trigger",
+							Data: &rpc.TypedData{
+								Data: &rpc.TypedData_Http{
+									Http: &rpc.RpcHttp{},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	select {
+	case err := <-listenErr:
+		assert.EqualError(t, err, "worker restart required after 2 panics")
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not restart after panics")
+	}
+	close(stream.sent)
+	responses := 0
+	for msg := range stream.sent {
+		if resp, ok := msg.Content.(*rpc.StreamingMessage_InvocationResponse); ok {
+			responses++
+			exception := resp.InvocationResponse.Result.Exception
+			assert.Equal(t, rpc.StatusResult_Failure, resp.InvocationResponse.Result.Status)
+			assert.Equal(t, "panic: function failed", exception.Message)
+			assert.Equal(t, "func", exception.Source)
+			assert.Contains(t, exception.StackTrace, "worker_test.MockPanicFunc.Run")
+		}
+	}
+	assert.Equal(t, 2, responses)
+}
+
+// queuedFuncHooks are injected into MockQueuedFunc, so that each test controls it's own invocations
+type queuedFuncHooks struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+type MockQueuedFunc struct {
+	Hooks *queuedFuncHooks `azfunc:"inject"`
+}
+
+func (m *MockQueuedFunc) Run(ctx context.Context, logger api.Logger) {
+	atomic.AddInt32(&m.Hooks.calls, 1)
+	m.Hooks.started <- struct{}{}
+	<-m.Hooks.release
+}
+
+func TestWorkerCancelsQueuedInvocation(t *testing.T) {
+	var mockLoader mocks.TypeLoader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockQueuedFunc)(nil)), nil)
+	hooks := &queuedFuncHooks{
+		started: make(chan struct{}, 2),
+		release: make(chan struct{}),
+	}
+	services := api.NewServices()
+	services.Register(hooks)
+	stream := &memoryEventStream{
+		recv: make(chan *rpc.StreamingMessage),
+		sent: make(chan *rpc.StreamingMessage, 100),
+	}
+	worker := worker.Worker{
+		WorkerID:               "mockWorkerID",
+		RequestID:              "mockRequestID",
+		Stream:                 stream,
+		MaxFunctionConcurrency: 1,
+		Loader: worker.Loader{
+			TypeLoader:      &mockLoader,
+			LoadedFunctions: map[string]worker.Function{},
+			Services:        services,
+		},
+	}
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- worker.Listen()
+	}()
+	stream.recv <- &rpc.StreamingMessage{
+		RequestId: "mockRequestId",
+		Content: &rpc.StreamingMessage_FunctionLoadRequest{
+			FunctionLoadRequest: &rpc.FunctionLoadRequest{
+				FunctionId: "mockFunctionId",
+				Metadata: &rpc.RpcFunctionMetadata{
+					Name: "func",
+					Bindings: map[string]*rpc.BindingInfo{
+						"trigger": &rpc.BindingInfo{
+							Type:      "httpTrigger",
+							Direction: rpc.BindingInfo_in,
+						},
+					},
+				},
+			},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		stream.recv <- &rpc.StreamingMessage{
+			RequestId: "mockRequestId",
+			Content: &rpc.StreamingMessage_InvocationRequest{
+				InvocationRequest: &rpc.InvocationRequest{
+					FunctionId:   "mockFunctionId",
+					InvocationId: fmt.Sprintf("mockInvocationId%d", i),
+					InputData: []*rpc.ParameterBinding{
+						&rpc.ParameterBinding{
+							Name: "trigger",
+							Data: &rpc.TypedData{
+								Data: &rpc.TypedData_Http{
+									Http: &rpc.RpcHttp{},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		if i == 0 {
+			// the first invocation holds the only slot of function, the second one is queued
+			<-hooks.started
+		}
+	}
+	stream.recv <- &rpc.StreamingMessage{
+		RequestId: "mockCancelRequestId",
+		Content: &rpc.StreamingMessage_InvocationCancel{
+			InvocationCancel: &rpc.InvocationCancel{
+				InvocationId: "mockInvocationId1",
+				GracePeriod: &duration.Duration{
+					Seconds: 5,
+				},
+			},
+		},
+	}
+	// messages are handled in order, so cancel is handled once heartbeat is answered
+	stream.recv <- &rpc.StreamingMessage{
+		RequestId: "mockHeartbeatRequestId",
+		Content: &rpc.StreamingMessage_WorkerHeartbeat{
+			WorkerHeartbeat: &rpc.WorkerHeartbeat{},
+		},
+	}
+	for msg := range stream.sent {
+		if msg.GetWorkerHeartbeat() != nil {
+			break
+		}
+	}
+	close(hooks.release)
+	close(stream.recv)
+	select {
+	case err := <-listenErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not exit")
+	}
+	close(stream.sent)
+	var responses []*rpc.InvocationResponse
+	for msg := range stream.sent {
+		if resp := msg.GetInvocationResponse(); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if assert.Len(t, responses, 2) {
+		statuses := map[string]rpc.StatusResult_Status{}
+		for _, resp := range responses {
+			statuses[resp.InvocationId] = resp.Result.Status
+		}
+		assert.Equal(t, map[string]rpc.StatusResult_Status{
+			"mockInvocationId0": rpc.StatusResult_Success,
+			"mockInvocationId1": rpc.StatusResult_Cancelled,
+		}, statuses)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hooks.calls))
+}
+
+type MockClosingReloadedFunc struct {
+	Hooks *runningFuncHooks `azfunc:"inject"`
+}
+
+func (m *MockClosingReloadedFunc) Run(ctx context.Context, logger api.Logger) {}
+
+func (m *MockClosingReloadedFunc) Close() error {
+	m.Hooks.closed <- struct{}{}
+	return nil
+}
+
+func TestWorkerWaitsForReloadBeforeClosingFunctions(t *testing.T) {
+	reloadStarted := make(chan struct{})
+	releaseReload := make(chan struct{})
+	var mockLoader mockReloader
+	mockLoader.On("GetFunctionType", mock.Anything, mock.Anything).Return(reflect.TypeOf((*MockOriginalFuncForChannel)(nil)).Elem(), nil)
+	mockLoader.On("ReloadFunctionType", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(reloadStarted)
+		<-releaseReload
+	}).Return(reflect.TypeOf((*MockClosingReloadedFunc)(nil)), nil)
+	hooks := newRunningFuncHooks()
+	services := api.NewServices()
+	services.Register(hooks)
+	stream := &memoryEventStream{
+		recv: make(chan *rpc.StreamingMessage),
+		sent: make(chan *rpc.StreamingMessage, 100),
+	}
+	worker := worker.Worker{
+		WorkerID:  "mockWorkerID",
+		RequestID: "mockRequestID",
+		Stream:    stream,
+		Loader: worker.Loader{
+			TypeLoader:      &mockLoader,
+			LoadedFunctions: map[string]worker.Function{},
+			Services:        services,
+		},
+	}
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- worker.Listen()
+	}()
+	stream.recv <- &rpc.StreamingMessage{
+		RequestId: "mockRequestId",
+		Content: &rpc.StreamingMessage_FunctionLoadRequest{
+			FunctionLoadRequest: &rpc.FunctionLoadRequest{
+				FunctionId: "mockFunctionId",
+				Metadata: &rpc.RpcFunctionMetadata{
+					Name:      "func",
+					Directory: filepath.Join("app", "func"),
+					Bindings: map[string]*rpc.BindingInfo{
+						"trigger": &rpc.BindingInfo{
+							Type:      "httpTrigger",
+							Direction: rpc.BindingInfo_in,
+						},
+					},
+				},
+			},
+		},
+	}
+	stream.recv <- &rpc.StreamingMessage{
+		RequestId: "mockRequestId",
+		Content: &rpc.StreamingMessage_FileChangeEventRequest{
+			FileChangeEventRequest: &rpc.FileChangeEventRequest{
+				Type:     rpc.FileChangeEventRequest_Changed,
+				FullPath: filepath.Join("app", "func", "function.go"),
+			},
+		},
+	}
+	<-reloadStarted
+	close(stream.recv)
+	select {
+	case <-listenErr:
+		t.Fatal("worker exited while function was reloading")
+	case <-time.After(time.Millisecond * 50):
+	}
+	close(releaseReload)
+	select {
+	case err := <-listenErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("worker did not exit")
+	}
+	// reloaded version was swapped in before functions were closed
+	assert.Len(t, hooks.closed, 1)
+}